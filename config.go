@@ -64,12 +64,20 @@ package goflagconfig
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
+	"unicode"
 )
 
 // -- bool Value
@@ -82,14 +90,26 @@ func newBoolValue(val bool, p *bool) *boolValue {
 
 func (b *boolValue) Set(s string) error {
 	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
 	*b = boolValue(v)
-	return err
+	return nil
 }
 
 func (b *boolValue) Get() interface{} { return bool(*b) }
 
 func (b *boolValue) String() string { return strconv.FormatBool(bool(*b)) }
 
+func (b *boolValue) IsBoolConfig() bool { return true }
+
+// optional interface to indicate boolean configs that can be
+// supplied without "=value" text
+type boolConfig interface {
+	Value
+	IsBoolConfig() bool
+}
+
 // -- int Value
 type intValue int
 
@@ -99,9 +119,12 @@ func newIntValue(val int, p *int) *intValue {
 }
 
 func (i *intValue) Set(s string) error {
-	v, err := strconv.ParseInt(s, 0, 64)
+	v, err := strconv.ParseInt(s, 0, strconv.IntSize)
+	if err != nil {
+		return err
+	}
 	*i = intValue(v)
-	return err
+	return nil
 }
 
 func (i *intValue) Get() interface{} { return int(*i) }
@@ -118,14 +141,38 @@ func newInt64Value(val int64, p *int64) *int64Value {
 
 func (i *int64Value) Set(s string) error {
 	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return err
+	}
 	*i = int64Value(v)
-	return err
+	return nil
 }
 
 func (i *int64Value) Get() interface{} { return int64(*i) }
 
 func (i *int64Value) String() string { return strconv.FormatInt(int64(*i), 10) }
 
+// -- int32 Value
+type int32Value int32
+
+func newInt32Value(val int32, p *int32) *int32Value {
+	*p = val
+	return (*int32Value)(p)
+}
+
+func (i *int32Value) Set(s string) error {
+	v, err := strconv.ParseInt(s, 0, 32)
+	if err != nil {
+		return err
+	}
+	*i = int32Value(v)
+	return nil
+}
+
+func (i *int32Value) Get() interface{} { return int32(*i) }
+
+func (i *int32Value) String() string { return strconv.FormatInt(int64(*i), 10) }
+
 // -- uint Value
 type uintValue uint
 
@@ -135,9 +182,12 @@ func newUintValue(val uint, p *uint) *uintValue {
 }
 
 func (i *uintValue) Set(s string) error {
-	v, err := strconv.ParseUint(s, 0, 64)
+	v, err := strconv.ParseUint(s, 0, strconv.IntSize)
+	if err != nil {
+		return err
+	}
 	*i = uintValue(v)
-	return err
+	return nil
 }
 
 func (i *uintValue) Get() interface{} { return uint(*i) }
@@ -154,14 +204,38 @@ func newUint64Value(val uint64, p *uint64) *uint64Value {
 
 func (i *uint64Value) Set(s string) error {
 	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return err
+	}
 	*i = uint64Value(v)
-	return err
+	return nil
 }
 
 func (i *uint64Value) Get() interface{} { return uint64(*i) }
 
 func (i *uint64Value) String() string { return strconv.FormatUint(uint64(*i), 10) }
 
+// -- uint32 Value
+type uint32Value uint32
+
+func newUint32Value(val uint32, p *uint32) *uint32Value {
+	*p = val
+	return (*uint32Value)(p)
+}
+
+func (i *uint32Value) Set(s string) error {
+	v, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return err
+	}
+	*i = uint32Value(v)
+	return nil
+}
+
+func (i *uint32Value) Get() interface{} { return uint32(*i) }
+
+func (i *uint32Value) String() string { return strconv.FormatUint(uint64(*i), 10) }
+
 // -- string Value
 type stringValue string
 
@@ -189,14 +263,38 @@ func newFloat64Value(val float64, p *float64) *float64Value {
 
 func (f *float64Value) Set(s string) error {
 	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
 	*f = float64Value(v)
-	return err
+	return nil
 }
 
 func (f *float64Value) Get() interface{} { return float64(*f) }
 
 func (f *float64Value) String() string { return strconv.FormatFloat(float64(*f), 'g', -1, 64) }
 
+// -- float32 Value
+type float32Value float32
+
+func newFloat32Value(val float32, p *float32) *float32Value {
+	*p = val
+	return (*float32Value)(p)
+}
+
+func (f *float32Value) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return err
+	}
+	*f = float32Value(v)
+	return nil
+}
+
+func (f *float32Value) Get() interface{} { return float32(*f) }
+
+func (f *float32Value) String() string { return strconv.FormatFloat(float64(*f), 'g', -1, 32) }
+
 // -- time.Duration Value
 type durationValue time.Duration
 
@@ -207,8 +305,11 @@ func newDurationValue(val time.Duration, p *time.Duration) *durationValue {
 
 func (d *durationValue) Set(s string) error {
 	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
 	*d = durationValue(v)
-	return err
+	return nil
 }
 
 func (d *durationValue) Get() interface{} { return time.Duration(*d) }
@@ -228,27 +329,301 @@ func (d *durationValue) String() string { return (*time.Duration)(d).String() }
 type Value interface {
 	String() string
 	Set(string) error
-	Get() interface{}
+}
+
+// A statefulValue is a Value whose Set does not simply replace its value,
+// such as one that appends to a list, so that it cannot be restored by
+// setting its String form. saveState returns a function that restores the
+// Value to its current state.
+type statefulValue interface {
+	saveState() (restore func())
+}
+
+// saveValue returns a function that restores value to its current state.
+func saveValue(value Value) func() {
+	if s, ok := value.(statefulValue); ok {
+		return s.saveState()
+	}
+	old := value.String()
+	return func() { value.Set(old) }
+}
+
+// A valueMaker is a Value that cannot be copied by allocating its zero
+// value, such as one holding a pointer to its variable. newValue returns a
+// Value of the same kind with a variable of its own.
+type valueMaker interface {
+	newValue() Value
+}
+
+// newValue returns a new Value of the same type as value, or nil if that
+// is not possible.
+func newValue(value Value) Value {
+	if m, ok := value.(valueMaker); ok {
+		return m.newValue()
+	}
+	t := reflect.TypeOf(value)
+	if t.Kind() != reflect.Ptr {
+		return nil
+	}
+	v, _ := reflect.New(t.Elem()).Interface().(Value)
+	return v
+}
+
+// A snapshotValue is a read-only copy of a Value at some point in time.
+type snapshotValue struct {
+	s string
+	v interface{}
+}
+
+func (s *snapshotValue) Set(string) error { return errors.New("read-only value") }
+
+func (s *snapshotValue) Get() interface{} { return s.v }
+
+func (s *snapshotValue) String() string { return s.s }
+
+// A resetter is a Value whose Set adds to the value left by earlier calls.
+// reset replaces the value with s, as the first call to Set does, and makes
+// the next call to Set replace it again.
+type resetter interface {
+	reset(s string) error
+}
+
+// An Appender is a Value, such as a list, whose value can hold several
+// values given to it in turn. Append adds the value s to it, even if it
+// has not been set before. When a config file names an Appender config on
+// more than one line, Load sets it to the value on the first line,
+// replacing any value it had before, and appends the value on each later
+// line. Other configs take the value on the last line.
+type Appender interface {
+	Value
+	Append(s string) error
+}
+
+// appendValue adds s to value if it is an Appender, and sets it otherwise.
+func appendValue(value Value, s string) error {
+	if a, ok := value.(Appender); ok {
+		return a.Append(s)
+	}
+	return value.Set(s)
+}
+
+// resetValue sets value to s as if it had not been set before.
+func resetValue(value Value, s string) error {
+	if r, ok := value.(resetter); ok {
+		return r.reset(s)
+	}
+	return value.Set(s)
 }
 
 // Getter is an interface that allows the contents of a Value to be retrieved.
-// It wraps the Value interface, rather than being part of it, because it
-// appeared after Go 1 and its compatibility rules. All Value types provided
-// by this package satisfy the Getter interface.
-/*
+// It mirrors flag.Getter, which wraps flag.Value rather than being part of
+// it. All Value types provided by this package satisfy the Getter interface.
 type Getter interface {
 	Value
 	Get() interface{}
 }
-*/
+
+// getValue returns the contents of value: the result of its Get method if
+// it is a Getter, and its String form otherwise.
+func getValue(value Value) interface{} {
+	if g, ok := value.(Getter); ok {
+		return g.Get()
+	}
+	return value.String()
+}
+
+// ErrHelp is the error returned if the -help or -h config is invoked
+// but no such config is defined.
+var ErrHelp = errors.New("config: help requested")
+
+// ErrUnknownConfig is the error wrapped by the error Parse returns for a
+// command-line config that is not defined.
+var ErrUnknownConfig = errors.New("config provided but not defined")
+
+// ErrSection is the error returned by the methods of a section, as returned
+// by Section, that act on a whole config set, such as Parse, Load and Save.
+var ErrSection = errors.New("config: not supported on a section")
+
+// ErrFrozen is the error returned by attempts to change a config set that
+// has been frozen with Freeze.
+var ErrFrozen = errors.New("config: config set is frozen")
+
+// ErrorHandling defines how ConfigSet.Parse and ConfigSet.Load behave if
+// the parse fails.
+type ErrorHandling int
+
+// These constants cause ConfigSet.Parse and ConfigSet.Load to behave as
+// described if the parse fails.
+const (
+	ContinueOnError ErrorHandling = iota // Return a descriptive error.
+	ExitOnError                          // Call os.Exit(2).
+	PanicOnError                         // Call panic with a descriptive error.
+)
 
 // A ConfigSet represents a set of defined configs. The zero value of a ConfigSet
 // has no name and has ContinueOnError error handling.
+//
+// Set, Parse, the Load and Save methods and Resolve may be called while the
+// set is being reloaded by Watch. Defining configs, and reading values other
+// than through these methods, is not synchronized.
 type ConfigSet struct {
-	filename string
-	parsed   bool
-	actual   map[string]*Config
-	formal   map[string]*Config
+	// PreserveLayout makes Load remember the comments, blank lines and key
+	// order of the file it reads, and Save replay them, rewriting only the
+	// values that changed. Configs not present in the loaded file are
+	// appended at the end. It must be set before Load is called.
+	PreserveLayout bool
+
+	// ExpandEnv makes Load replace ${VAR} and $VAR in values with the
+	// value of the environment variable VAR, and $$ with a literal $.
+	// Variables that are not set expand to the empty string unless
+	// StrictEnv is also set, in which case they are an error.
+	ExpandEnv bool
+	StrictEnv bool
+
+	// AllowPrefixMatch makes Parse and Lookup accept a prefix of a config
+	// name that no other config name or alias begins with, as if it were
+	// the whole name. A name that is defined always means that config, and
+	// a prefix shared by several names is an error naming them.
+	AllowPrefixMatch bool
+
+	// RequireFiles makes LoadFiles return an error for a file that does
+	// not exist instead of skipping it.
+	RequireFiles bool
+
+	// ExpandConfigs makes Load replace ${name} in values with the value of
+	// the config name: its value in the same file if the file sets it, and
+	// its current value otherwise. A reference cycle is an error. If
+	// ExpandEnv is also set, names that are not configs are left to it;
+	// otherwise they are an error. $$ stands for a literal $.
+	ExpandConfigs bool
+
+	// RejectDisallowed makes LoadAllowed treat a line setting a config
+	// that is not allowed as an error instead of skipping it.
+	RejectDisallowed bool
+
+	// AllowUnknown makes Set, and so Load, define a string config for a
+	// name that is not defined instead of returning an error.
+	AllowUnknown bool
+
+	// CaseInsensitive makes Lookup, Set, Parse and Load match config names
+	// and aliases regardless of case. Configs keep the name they were
+	// defined with for Save and Print, and names that differ only in case
+	// cannot both be defined.
+	CaseInsensitive bool
+
+	// KeyNormalizer, if not nil, makes Lookup, Set, Parse and Load match a
+	// name to the config or alias whose name KeyNormalizer maps to the same
+	// string, so that with NormalizeKey the names max-conns, max_conns and
+	// maxConns all refer to one config. Configs keep the name they were
+	// defined with, and names that normalize alike cannot both be defined.
+	KeyNormalizer func(string) string
+
+	// AllowFileRefs makes a value of the form @path stand for the contents
+	// of the file at path, less a trailing newline, wherever a value is set.
+	// A value beginning with @@ stands for itself less the first @. Save
+	// writes the contents rather than the reference.
+	AllowFileRefs bool
+
+	// LenientBool makes bool configs also accept yes, no, on, off, enabled
+	// and disabled, in any case. They are still written as true or false.
+	LenientBool bool
+
+	// EnableNegation makes Parse accept -no-name for a bool config name
+	// that is defined, setting it to false, unless a config named no-name
+	// is itself defined.
+	EnableNegation bool
+
+	// RedactSecrets makes Save, SaveTo and Update write a placeholder
+	// instead of the value of each config marked with SetSecret. Use
+	// SaveWithSecrets to save the real values.
+	RedactSecrets bool
+
+	// RawQuotes makes Load take the text between the double quotes of a
+	// quoted value literally, so that a backslash in it stands for itself
+	// rather than beginning an escape sequence such as \n or \t, unless the
+	// text contains a '"' or ends in a backslash. Save then writes such
+	// values between double quotes without escapes where it can, falling
+	// back on a Go quoted string for values that contain a '"', a newline
+	// or a carriage return, or that end in a backslash. Load reads such a
+	// string back unless it has a carriage return but neither a '"' nor a
+	// trailing backslash.
+	RawQuotes bool
+
+	// CommentDefaults makes Save write the configs that have not been set
+	// commented out, as # name=value # usage, so that the file lists every
+	// config and its default without setting them when it is loaded.
+	CommentDefaults bool
+
+	// AlignValues makes Save pad the keys of the configs it writes in each
+	// section so that their '=' signs line up, as Print does.
+	AlignValues bool
+
+	// CommentChars are the characters that begin a comment in a file read
+	// by Load. If empty, both '#' and ';' do. Save always writes comments
+	// with '#'.
+	CommentChars string
+
+	// SaveOrder is the order in which Save writes configs within each
+	// section. The default is SortedOrder.
+	SaveOrder SaveOrder
+
+	// HideDeprecated makes Print and PrintDefaults leave out configs
+	// marked with Deprecate.
+	HideDeprecated bool
+
+	// Usage is the function called when Parse sees -h or -help and the
+	// config is not defined. By default it prints a usage message listing
+	// the configs to the output.
+	Usage func()
+
+	filename      string
+	parsed        bool
+	actual        map[string]*Config
+	formal        map[string]*Config
+	args          []string // arguments after configs
+	errorHandling ErrorHandling
+	output        io.Writer // nil means stderr; use Output() accessor
+	logger        io.Writer // nil means discard; see SetLogger
+	layout        []layoutLine
+	owner         *ConfigSet        // set holding the configs of a section
+	prefix        string            // section name and dot, for a section
+	env           map[string]string // environment variable bound to each config
+	envPrefix     string
+	validators    map[string]func(Value) error
+	changeFuncs   map[string][]func(old, new Value)
+	aliases       map[string]string // canonical name of each alias
+	folded        map[string]string // config or alias name by its lower-case form
+	order         []string          // config names in order of definition
+	name          string            // name of a subcommand
+	parent        *ConfigSet        // set a subcommand inherits configs from
+	subcommands   map[string]*ConfigSet
+	selected      *ConfigSet        // subcommand chosen by the last Parse
+	deprecated    map[string]string // deprecation message of each name
+	secrets       map[string]bool   // configs whose values are redacted
+	frozen        bool              // whether changes are refused; see Freeze
+	sources       map[string]string // where each config's value came from
+	tags          map[string][]string
+	warned        map[string]bool // deprecated names already warned about
+	reloadFuncs   []func()
+	pending       []func()   // change functions to call once f.mu is released
+	mu            sync.Mutex // serializes updates, such as reloads by Watch
+}
+
+// SaveOrder is the order in which Save writes configs.
+type SaveOrder int
+
+// These constants cause Save to write configs in the described order.
+const (
+	SortedOrder     SaveOrder = iota // Write configs sorted by name.
+	DefinitionOrder                  // Write configs in the order they were defined.
+)
+
+// A layoutLine is a line of a loaded config file, kept so that Save can
+// reproduce the file when PreserveLayout is set.
+type layoutLine struct {
+	text  string // line as read
+	key   string // config name, or "" for comment and blank lines
+	value string // value as read
 }
 
 // A Config represents the state of a config.
@@ -259,6 +634,56 @@ type Config struct {
 	DefValue string // default value (as text); for usage message
 }
 
+// IsDefault reports whether the config holds its default value, whether or
+// not it has been set.
+func (c *Config) IsDefault() bool {
+	return c.Value.String() == c.DefValue
+}
+
+// UsageName returns the placeholder to show for the config's value in a
+// usage message, and the usage string with the placeholder un-quoted. The
+// placeholder is the first back-quoted word in the usage string; given
+// "write output to `FILE`" it returns ("FILE", "write output to FILE"). If
+// there are no back quotes, the placeholder is a short name for the type
+// of the config's value, or empty for a bool config.
+func (c *Config) UsageName() (name string, usage string) {
+	usage = c.Usage
+	for i := 0; i < len(usage); i++ {
+		if usage[i] == '`' {
+			for j := i + 1; j < len(usage); j++ {
+				if usage[j] == '`' {
+					name = usage[i+1 : j]
+					usage = usage[:i] + name + usage[j+1:]
+					return name, usage
+				}
+			}
+			break // Only one back quote; use type name.
+		}
+	}
+	return typeName(c.Value), usage
+}
+
+// DefaultValue returns the default value of the config as its Value's Get
+// method would return it, by setting a new Value of the same type to
+// DefValue. It returns nil if that is not possible.
+func (c *Config) DefaultValue() interface{} {
+	v := newValue(c.Value)
+	if v == nil || resetValue(v, c.DefValue) != nil {
+		return nil
+	}
+	return getValue(v)
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // sortConfigs returns the configs as a slice in lexicographical sorted order.
 func sortConfigs(configs map[string]*Config) []*Config {
 	list := make(sort.StringSlice, len(configs))
@@ -275,23 +700,199 @@ func sortConfigs(configs map[string]*Config) []*Config {
 	return result
 }
 
+// Output returns the destination for error messages. If output was not set
+// or was set to nil, os.Stderr is returned.
+func (f *ConfigSet) Output() io.Writer {
+	if f.owner != nil {
+		return f.owner.Output()
+	}
+	if f.output == nil {
+		return os.Stderr
+	}
+	return f.output
+}
+
+// ErrorHandling returns the error handling behavior of the config set.
+func (f *ConfigSet) ErrorHandling() ErrorHandling {
+	return f.errorHandling
+}
+
+// SetOutput sets the destination for error messages.
+// If output is nil, os.Stderr is used.
+func (f *ConfigSet) SetOutput(output io.Writer) {
+	f.checkNotSection("SetOutput")
+	f.output = output
+}
+
+// SetLogger sets the destination for diagnostic messages, such as the
+// names of the files read by Load and written by Save. If logger is nil,
+// which is the default, diagnostics are discarded.
+func (f *ConfigSet) SetLogger(logger io.Writer) {
+	f.checkNotSection("SetLogger")
+	f.logger = logger
+}
+
+// logf writes a formatted diagnostic message to the logger, if any.
+func (f *ConfigSet) logf(format string, a ...interface{}) {
+	if f.logger != nil {
+		fmt.Fprintf(f.logger, format+"\n", a...)
+	}
+}
+
+// orderedConfigs returns the configs in the order they were defined.
+func (f *ConfigSet) orderedConfigs() []*Config {
+	result := make([]*Config, 0, len(f.formal))
+	for _, name := range f.order {
+		if config, ok := f.formal[name]; ok {
+			result = append(result, config)
+		}
+	}
+	return result
+}
+
+// saveConfigs returns the configs to save in the order given by SaveOrder,
+// leaving out those defined by Func, which hold no value to save. If
+// redact is true, secret configs are replaced by copies whose Value is
+// redacted.
+func (f *ConfigSet) saveConfigs(redact bool) []*Config {
+	var all []*Config
+	if f.SaveOrder == DefinitionOrder {
+		all = f.orderedConfigs()
+	} else {
+		all = sortConfigs(f.formal)
+	}
+	configs := all[:0]
+	for _, config := range all {
+		if _, ok := config.Value.(funcValue); ok {
+			continue
+		}
+		if redact {
+			config = f.redacted(config)
+		}
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+// redacted returns config, or a copy with its value redacted if it is
+// secret.
+func (f *ConfigSet) redacted(config *Config) *Config {
+	if !f.secrets[config.Name] {
+		return config
+	}
+	c := *config
+	c.Value = &snapshotValue{redactedValue, redactedValue}
+	return &c
+}
+
 // VisitAll visits the configs in lexicographical order, calling fn for each.
 // It visits all configs, even those not set.
 func (f *ConfigSet) VisitAll(fn func(*Config)) {
+	if f.owner != nil {
+		f.owner.VisitAll(f.inSection(fn))
+		return
+	}
 	for _, config := range sortConfigs(f.formal) {
 		fn(config)
 	}
 }
 
+// inSection returns a function that calls fn for the configs of the section
+// f, for visiting the configs of the set that holds them.
+func (f *ConfigSet) inSection(fn func(*Config)) func(*Config) {
+	return func(config *Config) {
+		if strings.HasPrefix(config.Name, f.prefix) {
+			fn(config)
+		}
+	}
+}
+
 // VisitAll visits the command-line configs in lexicographical order, calling
 // fn for each. It visits all configs, even those not set.
 func VisitAll(fn func(*Config)) {
 	Configuration.VisitAll(fn)
 }
 
+// Tag adds tags to the named config, so that VisitTagged, SaveTagged and
+// DumpTagged can pick out the configs of one part of a program.
+func (f *ConfigSet) Tag(name string, tags ...string) {
+	if f.owner != nil {
+		f.owner.Tag(f.prefix+name, tags...)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = f.canonical(name)
+	if f.tags == nil {
+		f.tags = make(map[string][]string)
+	}
+	for _, tag := range tags {
+		if !f.hasTag(name, tag) {
+			f.tags[name] = append(f.tags[name], tag)
+		}
+	}
+}
+
+// Tag adds tags to the named command-line config.
+func Tag(name string, tags ...string) {
+	Configuration.Tag(name, tags...)
+}
+
+// hasTag reports whether the named config has the tag.
+func (f *ConfigSet) hasTag(name, tag string) bool {
+	for _, t := range f.tags[name] {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// VisitTagged visits the configs that have the tag in lexicographical
+// order, calling fn for each.
+func (f *ConfigSet) VisitTagged(tag string, fn func(*Config)) {
+	if f.owner != nil {
+		f.owner.VisitTagged(tag, f.inSection(fn))
+		return
+	}
+	f.VisitAll(func(config *Config) {
+		if f.hasTag(config.Name, tag) {
+			fn(config)
+		}
+	})
+}
+
+// VisitTagged visits the command-line configs that have the tag in
+// lexicographical order, calling fn for each.
+func VisitTagged(tag string, fn func(*Config)) {
+	Configuration.VisitTagged(tag, fn)
+}
+
+// VisitAllOrdered visits the configs in the order they were defined,
+// calling fn for each. It visits all configs, even those not set.
+func (f *ConfigSet) VisitAllOrdered(fn func(*Config)) {
+	if f.owner != nil {
+		f.owner.VisitAllOrdered(f.inSection(fn))
+		return
+	}
+	for _, config := range f.orderedConfigs() {
+		fn(config)
+	}
+}
+
+// VisitAllOrdered visits the command-line configs in the order they were
+// defined, calling fn for each. It visits all configs, even those not set.
+func VisitAllOrdered(fn func(*Config)) {
+	Configuration.VisitAllOrdered(fn)
+}
+
 // Visit visits the configs in lexicographical order, calling fn for each.
 // It visits only those configs that have been set.
 func (f *ConfigSet) Visit(fn func(*Config)) {
+	if f.owner != nil {
+		f.owner.Visit(f.inSection(fn))
+		return
+	}
 	for _, config := range sortConfigs(f.actual) {
 		fn(config)
 	}
@@ -303,47 +904,992 @@ func Visit(fn func(*Config)) {
 	Configuration.Visit(fn)
 }
 
-// Lookup returns the Config structure of the named config, returning nil if none exists.
-func (f *ConfigSet) Lookup(name string) *Config {
-	return f.formal[name]
+// VisitUnset visits the configs in lexicographical order, calling fn for
+// each. It visits only those configs that have not been set.
+func (f *ConfigSet) VisitUnset(fn func(*Config)) {
+	if f.owner != nil {
+		f.owner.VisitUnset(f.inSection(fn))
+		return
+	}
+	for _, config := range sortConfigs(f.formal) {
+		if _, ok := f.actual[config.Name]; !ok {
+			fn(config)
+		}
+	}
 }
 
-// Lookup returns the Config structure of the named command-line config,
-// returning nil if none exists.
-func Lookup(name string) *Config {
-	return Configuration.formal[name]
+// VisitUnset visits the command-line configs in lexicographical order,
+// calling fn for each. It visits only those configs that have not been set.
+func VisitUnset(fn func(*Config)) {
+	Configuration.VisitUnset(fn)
 }
 
-// Set sets the value of the named config.
-func (f *ConfigSet) Set(name, value string) error {
-	config, ok := f.formal[name]
-	if !ok {
-		f.String(name, value, "")
-		fmt.Printf("Added config (string) %s = %s\n", name, value)
-		return nil
-		//return fmt.Errorf("no such config %v", name)
-	}
-	err := config.Value.Set(value)
-	if err != nil {
-		return err
+// Walk visits the configs in lexicographical order, calling fn for each,
+// as VisitAll does. If fn returns an error, Walk stops and returns it.
+func (f *ConfigSet) Walk(fn func(*Config) error) error {
+	if f.owner != nil {
+		return f.owner.Walk(func(config *Config) error {
+			if strings.HasPrefix(config.Name, f.prefix) {
+				return fn(config)
+			}
+			return nil
+		})
 	}
-	if f.actual == nil {
-		f.actual = make(map[string]*Config)
+	for _, config := range sortConfigs(f.formal) {
+		if err := fn(config); err != nil {
+			return err
+		}
 	}
-	f.actual[name] = config
 	return nil
 }
 
-// Set sets the value of the named command-line config.
-func Set(name, value string) error {
-	return Configuration.Set(name, value)
+// Walk visits the command-line configs in lexicographical order, calling fn
+// for each, until fn returns an error.
+func Walk(fn func(*Config) error) error {
+	return Configuration.Walk(fn)
 }
 
-// NConfig returns the number of configs that have been set.
-func (f *ConfigSet) NConfig() int { return len(f.actual) }
-
-// NConfig returns the number of command-line configs that have been set.
-func NConfig() int { return len(Configuration.actual) }
+// Lookup returns the Config structure of the named config, returning nil if none exists.
+// If AllowPrefixMatch is set, name may be an unambiguous prefix of the
+// config's name.
+func (f *ConfigSet) Lookup(name string) *Config {
+	if f.owner != nil {
+		return f.owner.Lookup(f.prefix + name)
+	}
+	_, config := f.find(name)
+	if config == nil && f.AllowPrefixMatch {
+		if full, err := f.matchPrefix(name); err == nil && full != "" {
+			_, config = f.find(full)
+		}
+	}
+	return config
+}
+
+// Has reports whether the named config is defined, whether or not it has
+// been set. As with Lookup, name may be an alias, and a subcommand has the
+// configs it inherits from its parent, but prefixes are not matched.
+func (f *ConfigSet) Has(name string) bool {
+	if f.owner != nil {
+		return f.owner.Has(f.prefix + name)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, config := f.find(name)
+	return config != nil
+}
+
+// Has reports whether the named command-line config is defined.
+func Has(name string) bool {
+	return Configuration.Has(name)
+}
+
+// matchPrefix returns the name of the one config or alias, of f or a set
+// it inherits configs from, whose name begins with prefix, or "" if there
+// is none. It is an error if there is more than one.
+func (f *ConfigSet) matchPrefix(prefix string) (string, error) {
+	lower := strings.ToLower(prefix)
+	var matches []string
+	seen := make(map[string]bool)
+	for s := f; s != nil; s = s.parent {
+		names := make([]string, 0, len(s.formal)+len(s.aliases))
+		for name := range s.formal {
+			names = append(names, name)
+		}
+		for alias := range s.aliases {
+			names = append(names, alias)
+		}
+		for _, name := range names {
+			match := strings.HasPrefix(name, prefix)
+			if s.CaseInsensitive {
+				match = strings.HasPrefix(strings.ToLower(name), lower)
+			}
+			if match && !seen[name] {
+				seen[name] = true
+				matches = append(matches, name)
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", nil
+	case 1:
+		return matches[0], nil
+	}
+	sort.Strings(matches)
+	return "", fmt.Errorf("ambiguous config: -%s could be -%s", prefix, strings.Join(matches, ", -"))
+}
+
+// find returns the named config and the set that defines it, which is f
+// or, for a subcommand, the nearest parent that does.
+func (f *ConfigSet) find(name string) (*ConfigSet, *Config) {
+	if config, ok := f.formal[f.canonical(name)]; ok || f.parent == nil {
+		return f, config
+	}
+	return f.parent.find(name)
+}
+
+// Changed reports whether the named config has been set, by Set, Parse,
+// Load or any other means, even if it was set to its default value.
+func (f *ConfigSet) Changed(name string) bool {
+	if f.owner != nil {
+		return f.owner.Changed(f.prefix + name)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.actual[f.canonical(name)]
+	return ok
+}
+
+// Changed reports whether the named command-line config has been set.
+func Changed(name string) bool {
+	return Configuration.Changed(name)
+}
+
+// Lookup returns the Config structure of the named command-line config,
+// returning nil if none exists.
+func Lookup(name string) *Config {
+	return Configuration.Lookup(name)
+}
+
+// defined returns the name of the config or alias that name refers to,
+// which differs from name, if at all, in case under CaseInsensitive or in
+// spelling under KeyNormalizer.
+func (f *ConfigSet) defined(name string) string {
+	if !f.CaseInsensitive && f.KeyNormalizer == nil {
+		return name
+	}
+	if _, ok := f.formal[name]; ok {
+		return name
+	}
+	if _, ok := f.aliases[name]; ok {
+		return name
+	}
+	if f.CaseInsensitive {
+		if d, ok := f.folded[strings.ToLower(name)]; ok {
+			return d
+		}
+	}
+	if f.KeyNormalizer != nil {
+		return f.normalized(name)
+	}
+	return name
+}
+
+// normalized returns the name of the config or alias that KeyNormalizer
+// maps to the same string as name, or name if there is none.
+func (f *ConfigSet) normalized(name string) string {
+	names := make([]string, 0, len(f.formal)+len(f.aliases))
+	for d := range f.formal {
+		names = append(names, d)
+	}
+	for d := range f.aliases {
+		names = append(names, d)
+	}
+	sort.Strings(names)
+	key := f.KeyNormalizer(name)
+	for _, d := range names {
+		if f.KeyNormalizer(d) == key {
+			return d
+		}
+	}
+	return name
+}
+
+// NormalizeKey is a KeyNormalizer that writes a name in lower case with
+// words separated by dashes: it replaces underscores with dashes and puts
+// a dash before each upper-case letter that follows a lower-case letter or
+// digit, so that max_conns, MAX_CONNS and maxConns all become max-conns.
+func NormalizeKey(name string) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range name {
+		switch {
+		case r == '_':
+			r = '-'
+		case unicode.IsUpper(r):
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+				b.WriteByte('-')
+			}
+		}
+		prev = r
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// fold records name as defined for matching regardless of case.
+func (f *ConfigSet) fold(name string) {
+	if f.folded == nil {
+		f.folded = make(map[string]string)
+	}
+	f.folded[strings.ToLower(name)] = name
+}
+
+// unfold forgets name as recorded by fold.
+func (f *ConfigSet) unfold(name string) {
+	if f.folded[strings.ToLower(name)] == name {
+		delete(f.folded, strings.ToLower(name))
+	}
+}
+
+// canonical returns the name of the config that name is an alias for, or
+// name itself if it is not an alias.
+func (f *ConfigSet) canonical(name string) string {
+	name = f.defined(name)
+	if c, ok := f.aliases[name]; ok {
+		return c
+	}
+	return name
+}
+
+// Alias makes alias another name for the canonical config, so that Lookup,
+// Set, Parse and Load accept either name and refer to the same Config.
+// The alias is not listed by VisitAll or written by Save. It is an error
+// if canonical is not defined or alias is already in use.
+func (f *ConfigSet) Alias(alias, canonical string) error {
+	if f.owner != nil {
+		return f.owner.Alias(f.prefix+alias, f.prefix+canonical)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	canonical = f.canonical(canonical)
+	if _, ok := f.formal[canonical]; !ok {
+		return fmt.Errorf("no such config %v", canonical)
+	}
+	if _, ok := f.formal[f.defined(alias)]; ok {
+		return fmt.Errorf("alias %s is already a config", alias)
+	}
+	if _, ok := f.aliases[f.defined(alias)]; ok {
+		return fmt.Errorf("alias %s is already an alias", alias)
+	}
+	if f.aliases == nil {
+		f.aliases = make(map[string]string)
+	}
+	f.aliases[alias] = canonical
+	f.fold(alias)
+	return nil
+}
+
+// Alias makes alias another name for the canonical command-line config.
+func Alias(alias, canonical string) error {
+	return Configuration.Alias(alias, canonical)
+}
+
+// redactedValue is shown in place of the value of a secret config.
+const redactedValue = "****"
+
+// SetSecret marks the named config as secret, so that Print, Dump and
+// PrintDefaults, and Save if RedactSecrets is set, show a placeholder
+// instead of its value. The value can still be read and set as usual.
+func (f *ConfigSet) SetSecret(name string) {
+	if f.owner != nil {
+		f.owner.SetSecret(f.prefix + name)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.secrets == nil {
+		f.secrets = make(map[string]bool)
+	}
+	f.secrets[f.canonical(name)] = true
+}
+
+// SetSecret marks the named command-line config as secret.
+func SetSecret(name string) {
+	Configuration.SetSecret(name)
+}
+
+// Deprecate marks the named config or alias as deprecated. It keeps
+// working, but the first time it is set a warning including message is
+// written to the logger.
+func (f *ConfigSet) Deprecate(name, message string) {
+	if f.owner != nil {
+		f.owner.Deprecate(f.prefix+name, message)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.deprecated == nil {
+		f.deprecated = make(map[string]string)
+	}
+	f.deprecated[name] = message
+}
+
+// Deprecate marks the named command-line config or alias as deprecated.
+func Deprecate(name, message string) {
+	Configuration.Deprecate(name, message)
+}
+
+// get returns the value of the named config.
+func (f *ConfigSet) get(name string) (interface{}, error) {
+	config := f.Lookup(name)
+	if config == nil {
+		return nil, fmt.Errorf("no such config %v", name)
+	}
+	return getValue(config.Value), nil
+}
+
+// GetString returns the value of the named string config.
+func (f *ConfigSet) GetString(name string) (string, error) {
+	v, err := f.get(name)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("config %s is %T, not string", name, v)
+	}
+	return s, nil
+}
+
+// GetString returns the value of the named command-line string config.
+func GetString(name string) (string, error) {
+	return Configuration.GetString(name)
+}
+
+// GetInt returns the value of the named int config.
+func (f *ConfigSet) GetInt(name string) (int, error) {
+	v, err := f.get(name)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := v.(int)
+	if !ok {
+		return 0, fmt.Errorf("config %s is %T, not int", name, v)
+	}
+	return i, nil
+}
+
+// GetInt returns the value of the named command-line int config.
+func GetInt(name string) (int, error) {
+	return Configuration.GetInt(name)
+}
+
+// GetBool returns the value of the named bool config.
+func (f *ConfigSet) GetBool(name string) (bool, error) {
+	v, err := f.get(name)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("config %s is %T, not bool", name, v)
+	}
+	return b, nil
+}
+
+// GetBool returns the value of the named command-line bool config.
+func GetBool(name string) (bool, error) {
+	return Configuration.GetBool(name)
+}
+
+// GetFloat64 returns the value of the named float64 config.
+func (f *ConfigSet) GetFloat64(name string) (float64, error) {
+	v, err := f.get(name)
+	if err != nil {
+		return 0, err
+	}
+	x, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("config %s is %T, not float64", name, v)
+	}
+	return x, nil
+}
+
+// GetFloat64 returns the value of the named command-line float64 config.
+func GetFloat64(name string) (float64, error) {
+	return Configuration.GetFloat64(name)
+}
+
+// GetDuration returns the value of the named time.Duration config.
+func (f *ConfigSet) GetDuration(name string) (time.Duration, error) {
+	v, err := f.get(name)
+	if err != nil {
+		return 0, err
+	}
+	d, ok := v.(time.Duration)
+	if !ok {
+		return 0, fmt.Errorf("config %s is %T, not time.Duration", name, v)
+	}
+	return d, nil
+}
+
+// GetDuration returns the value of the named command-line time.Duration config.
+func GetDuration(name string) (time.Duration, error) {
+	return Configuration.GetDuration(name)
+}
+
+// Set sets the value of the named config. It is an error if the config is
+// not defined, unless AllowUnknown is set. A subcommand sets a config it
+// inherits in its parent. An error from setting the Value
+// or from its validator is wrapped in one naming the config and value.
+func (f *ConfigSet) Set(name, value string) error {
+	if f.owner != nil {
+		return f.owner.Set(f.prefix+name, value)
+	}
+	if target, config := f.find(name); config != nil && target != f {
+		return target.Set(name, value)
+	}
+	f.mu.Lock()
+	defer f.unlock()
+	if f.frozen {
+		return f.errFrozen()
+	}
+	err := f.setFrom(name, value, "set")
+	if err != nil && f.formal[f.canonical(name)] != nil {
+		err = fmt.Errorf("invalid value %q for config -%s: %w", value, name, err)
+	}
+	return err
+}
+
+// A setMode says how set changes the value of a config.
+type setMode int
+
+const (
+	setNormal  setMode = iota // Call Set.
+	setReplace                // Set the Value as if for the first time.
+	setAppend                 // Add to the value of an Appender.
+)
+
+// set sets the value of the named config as mode says. The caller must hold
+// f.mu.
+func (f *ConfigSet) set(name, value string, mode setMode) error {
+	name, value, err := f.prepare(name, value)
+	if err != nil {
+		return err
+	}
+	return f.store(name, value, mode)
+}
+
+// prepare warns if name is deprecated and returns the canonical name and
+// the value with any file reference read. The caller must hold f.mu.
+func (f *ConfigSet) prepare(name, value string) (string, string, error) {
+	if f.frozen {
+		return name, value, ErrFrozen
+	}
+	name = f.defined(name)
+	if msg, ok := f.deprecated[name]; ok && !f.warned[name] {
+		if f.warned == nil {
+			f.warned = make(map[string]bool)
+		}
+		f.warned[name] = true
+		f.logf("config %s is deprecated: %s", name, msg)
+	}
+	name = f.canonical(name)
+	if f.AllowFileRefs && strings.HasPrefix(value, "@") {
+		var err error
+		if value, err = readFileRef(value); err != nil {
+			return name, value, err
+		}
+	}
+	return name, value, nil
+}
+
+// lenient returns value as the Value of config should be set to it,
+// accepting the forms of a boolean that LenientBool allows.
+func (f *ConfigSet) lenient(config *Config, value string) string {
+	if f.LenientBool {
+		if _, ok := getValue(config.Value).(bool); ok {
+			return lenientBool(value)
+		}
+	}
+	return value
+}
+
+// store sets the config with the canonical name to a value returned by
+// prepare, as set does. The caller must hold f.mu and release it with
+// unlock, which calls the change functions store queues.
+func (f *ConfigSet) store(name, value string, mode setMode) error {
+	config, ok := f.formal[name]
+	if !ok {
+		if !f.AllowUnknown {
+			return fmt.Errorf("no such config %v", name)
+		}
+		if err := f.define(newStringValue(value, new(string)), name, ""); err != nil {
+			return err
+		}
+		config = f.formal[name]
+	}
+	value = f.lenient(config, value)
+	validate := f.validators[name]
+	var restore func()
+	if validate != nil {
+		restore = saveValue(config.Value)
+	}
+	changeFuncs := f.changeFuncs[name]
+	var old Value
+	if changeFuncs != nil {
+		old = &snapshotValue{config.Value.String(), getValue(config.Value)}
+	}
+	var err error
+	switch mode {
+	case setReplace:
+		err = resetValue(config.Value, value)
+	case setAppend:
+		err = appendValue(config.Value, value)
+	default:
+		err = config.Value.Set(value)
+	}
+	if err != nil {
+		return err
+	}
+	if validate != nil {
+		if err := validate(config.Value); err != nil {
+			restore()
+			return err
+		}
+	}
+	if f.actual == nil {
+		f.actual = make(map[string]*Config)
+	}
+	f.actual[name] = config
+	if old != nil && old.String() != config.Value.String() {
+		for _, fn := range changeFuncs {
+			fn, new := fn, config.Value
+			f.pending = append(f.pending, func() { fn(old, new) })
+		}
+	}
+	return nil
+}
+
+// unlock releases f.mu and then calls the change functions that store
+// queued while it was held, so that they may use the set.
+func (f *ConfigSet) unlock() {
+	pending := f.pending
+	f.pending = nil
+	f.mu.Unlock()
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+// setFrom sets the value of the named config as set does and records
+// source as where the value came from. The caller must hold f.mu.
+func (f *ConfigSet) setFrom(name, value, source string) error {
+	if err := f.set(name, value, setNormal); err != nil {
+		return err
+	}
+	f.setSource(name, source)
+	return nil
+}
+
+// setSource records source as where the value of the named config came
+// from. The caller must hold f.mu.
+func (f *ConfigSet) setSource(name, source string) {
+	if f.sources == nil {
+		f.sources = make(map[string]string)
+	}
+	f.sources[f.canonical(name)] = source
+}
+
+// Source reports where the value of the named config came from:
+// "command-line" if it was set by Parse, "file:" and the file name if it
+// was loaded from a file, "env:" and the variable name if it was set from
+// the environment by Resolve, "set" if it was set by Set, and "default"
+// if it has not been set. Values read by LoadFrom or LoadBytes come from
+// "reader", and those read by LoadJSON from "json". Source returns "" if
+// the config is not defined.
+func (f *ConfigSet) Source(name string) string {
+	if f.owner != nil {
+		return f.owner.Source(f.prefix + name)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = f.canonical(name)
+	if _, ok := f.formal[name]; !ok {
+		return ""
+	}
+	if _, ok := f.actual[name]; !ok {
+		return "default"
+	}
+	if source, ok := f.sources[name]; ok {
+		return source
+	}
+	return "set"
+}
+
+// Source reports where the value of the named command-line config came
+// from.
+func Source(name string) string {
+	return Configuration.Source(name)
+}
+
+// lenientBool returns "true" or "false" for the yes, no, on, off, enabled
+// and disabled forms of a boolean, in any case, and s otherwise.
+func lenientBool(s string) string {
+	switch strings.ToLower(s) {
+	case "yes", "on", "enabled":
+		return "true"
+	case "no", "off", "disabled":
+		return "false"
+	}
+	return s
+}
+
+// readFileRef returns the value that the file reference ref, which begins
+// with '@', stands for.
+func readFileRef(ref string) (string, error) {
+	if strings.HasPrefix(ref, "@@") {
+		return ref[1:], nil
+	}
+	b, err := os.ReadFile(ref[1:])
+	if err != nil {
+		return "", err
+	}
+	s := strings.TrimSuffix(string(b), "\n")
+	return strings.TrimSuffix(s, "\r"), nil
+}
+
+// Set sets the value of the named command-line config.
+func Set(name, value string) error {
+	return Configuration.Set(name, value)
+}
+
+// SetBatch sets the named configs to the given values all together, or not
+// at all. Each value is first set on a copy of its config's Value and
+// checked by the config's validator; only if every value is accepted are
+// the configs themselves set, in order of name. Otherwise none of them is
+// changed and the error names the config that failed. A Func config cannot
+// be checked without calling its function, so an error from it is only
+// returned once the other configs have been set.
+func (f *ConfigSet) SetBatch(values map[string]string) error {
+	if f.owner != nil {
+		prefixed := make(map[string]string, len(values))
+		for name, value := range values {
+			prefixed[f.prefix+name] = value
+		}
+		return f.owner.SetBatch(prefixed)
+	}
+	f.mu.Lock()
+	defer f.unlock()
+	names := sortedKeys(values)
+	prepared := make(map[string]string, len(names))
+	for _, name := range names {
+		canonical, value, err := f.prepare(name, values[name])
+		if err == nil {
+			err = f.check(canonical, value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value %q for config -%s: %w", values[name], name, err)
+		}
+		prepared[canonical] = value
+	}
+	for _, name := range sortedKeys(prepared) {
+		if err := f.store(name, prepared[name], setNormal); err != nil {
+			return fmt.Errorf("invalid value %q for config -%s: %w", prepared[name], name, err)
+		}
+		f.setSource(name, "set")
+	}
+	return nil
+}
+
+// check reports whether the config with the canonical name would accept
+// value, by setting a copy of its Value. The caller must hold f.mu.
+func (f *ConfigSet) check(name, value string) error {
+	config, ok := f.formal[name]
+	if !ok {
+		if !f.AllowUnknown {
+			return fmt.Errorf("no such config %v", name)
+		}
+		return nil
+	}
+	if _, ok := config.Value.(funcValue); ok {
+		return nil
+	}
+	v := newValue(config.Value)
+	if v == nil {
+		return fmt.Errorf("cannot copy Value of type %T", config.Value)
+	}
+	if err := resetValue(v, config.Value.String()); err != nil {
+		return err
+	}
+	if err := v.Set(f.lenient(config, value)); err != nil {
+		return err
+	}
+	if validate := f.validators[name]; validate != nil {
+		return validate(v)
+	}
+	return nil
+}
+
+// SetBatch sets the named command-line configs to the given values all
+// together, or not at all.
+func SetBatch(values map[string]string) error {
+	return Configuration.SetBatch(values)
+}
+
+// SetValidator registers fn to check the value of the named config each
+// time it is set, whether by Set, Parse or Load. fn is called after the
+// value has been parsed; if it returns an error, the previous value is
+// restored, the config is not recorded as set and Set returns the error.
+func (f *ConfigSet) SetValidator(name string, fn func(Value) error) {
+	if f.owner != nil {
+		f.owner.SetValidator(f.prefix+name, fn)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.validators == nil {
+		f.validators = make(map[string]func(Value) error)
+	}
+	f.validators[f.canonical(name)] = fn
+}
+
+// SetValidator registers fn to check the value of the named command-line config.
+func SetValidator(name string, fn func(Value) error) {
+	Configuration.SetValidator(name, fn)
+}
+
+// Validate calls the validator registered for each config with its current
+// value, in order of config name, and returns an error naming the config
+// for each one that fails. It catches values that were valid when set but
+// no longer are, or that were set before their validator was registered.
+func (f *ConfigSet) Validate() []error {
+	if f.owner != nil {
+		return f.owner.validate(f.prefix)
+	}
+	return f.validate("")
+}
+
+// validate calls the validators of the configs whose names begin with
+// prefix, as Validate does.
+func (f *ConfigSet) validate(prefix string) []error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for _, config := range sortConfigs(f.formal) {
+		if !strings.HasPrefix(config.Name, prefix) {
+			continue
+		}
+		if validate := f.validators[config.Name]; validate != nil {
+			if err := validate(config.Value); err != nil {
+				errs = append(errs, fmt.Errorf("config %s: %w", config.Name, err))
+			}
+		}
+	}
+	return errs
+}
+
+// Validate calls the validator registered for each command-line config.
+func Validate() []error {
+	return Configuration.Validate()
+}
+
+// OnChange registers fn to be called whenever the value of the named config
+// changes, whether by Set, Parse, Load, Resolve or a reload by Watch. The
+// value has changed when its String form differs from before. old is a
+// read-only snapshot of the previous value and new is the config's Value.
+// Functions registered for a config are called in registration order once
+// the call that changed the value has released the set, so they may use
+// it; when one call changes several configs, as Load may, each function is
+// called after all of them have changed.
+func (f *ConfigSet) OnChange(name string, fn func(old, new Value)) {
+	if f.owner != nil {
+		f.owner.OnChange(f.prefix+name, fn)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.changeFuncs == nil {
+		f.changeFuncs = make(map[string][]func(old, new Value))
+	}
+	name = f.canonical(name)
+	f.changeFuncs[name] = append(f.changeFuncs[name], fn)
+}
+
+// OnChange registers fn to be called whenever the value of the named
+// command-line config changes.
+func OnChange(name string, fn func(old, new Value)) {
+	Configuration.OnChange(name, fn)
+}
+
+// ApplyDefaults changes the default value of each named config that has not
+// been set to the given value, setting the config to it and updating its
+// DefValue so that PrintDefaults and Save show it as the default. Configs
+// that have already been set are left alone. A name that is not defined is
+// an error, unless AllowUnknown is set, in which case a string config with
+// that default is defined. ApplyDefaults returns the errors from all the
+// values that could not be set.
+func (f *ConfigSet) ApplyDefaults(defaults map[string]string) error {
+	if f.owner != nil {
+		prefixed := make(map[string]string, len(defaults))
+		for name, value := range defaults {
+			prefixed[f.prefix+name] = value
+		}
+		return f.owner.ApplyDefaults(prefixed)
+	}
+	f.mu.Lock()
+	defer f.unlock()
+	if f.frozen {
+		return f.errFrozen()
+	}
+	var errs []error
+	for _, name := range sortedKeys(defaults) {
+		value := defaults[name]
+		config, ok := f.formal[f.canonical(name)]
+		if !ok {
+			if !f.AllowUnknown {
+				errs = append(errs, fmt.Errorf("config %s: no such config", name))
+				continue
+			}
+			if err := f.define(newStringValue(value, new(string)), name, ""); err != nil {
+				errs = append(errs, fmt.Errorf("config %s: %w", name, err))
+			}
+			continue
+		}
+		if _, ok := f.actual[config.Name]; ok {
+			continue
+		}
+		restore := saveValue(config.Value)
+		err := resetValue(config.Value, f.lenient(config, value))
+		if err == nil && f.validators[config.Name] != nil {
+			err = f.validators[config.Name](config.Value)
+		}
+		if err != nil {
+			restore()
+			errs = append(errs, fmt.Errorf("config %s: %w", name, err))
+			continue
+		}
+		config.DefValue = config.Value.String()
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyDefaults changes the default values of the named command-line
+// configs.
+func ApplyDefaults(defaults map[string]string) error {
+	return Configuration.ApplyDefaults(defaults)
+}
+
+// ResetToDefaults sets every config back to its default value and forgets
+// which configs have been set. A config whose default cannot be set again,
+// such as a custom Value whose Set rejects its own empty String form, keeps
+// its current value. It is an error to reset a frozen set.
+func (f *ConfigSet) ResetToDefaults() error {
+	if f.owner != nil {
+		return f.owner.resetToDefaults(f.prefix)
+	}
+	return f.resetToDefaults("")
+}
+
+// resetToDefaults resets the configs whose names begin with prefix, as
+// ResetToDefaults does.
+func (f *ConfigSet) resetToDefaults(prefix string) error {
+	f.mu.Lock()
+	defer f.unlock()
+	if f.frozen {
+		return f.errFrozen()
+	}
+	for name, config := range f.formal {
+		if strings.HasPrefix(name, prefix) {
+			resetValue(config.Value, config.DefValue)
+		}
+	}
+	f.forget(prefix)
+	return nil
+}
+
+// forget forgets which of the configs whose names begin with prefix have
+// been set. The caller must hold f.mu.
+func (f *ConfigSet) forget(prefix string) {
+	if prefix == "" {
+		f.actual = nil
+		f.sources = nil
+		return
+	}
+	for name := range f.actual {
+		if strings.HasPrefix(name, prefix) {
+			delete(f.actual, name)
+		}
+	}
+	for name := range f.sources {
+		if strings.HasPrefix(name, prefix) {
+			delete(f.sources, name)
+		}
+	}
+}
+
+// ResetToDefaults sets every command-line config back to its default value.
+func ResetToDefaults() error {
+	return Configuration.ResetToDefaults()
+}
+
+// Reset forgets which configs have been set and whether f has been parsed,
+// as if f had just been defined, so that Visit, Changed, NConfig and Source
+// see no configs set. Unlike ResetToDefaults, it does not change the values
+// of the configs.
+func (f *ConfigSet) Reset() {
+	if f.owner != nil {
+		f.owner.mu.Lock()
+		defer f.owner.mu.Unlock()
+		f.owner.forget(f.prefix)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.forget("")
+	f.parsed = false
+}
+
+// Reset forgets which command-line configs have been set.
+func Reset() {
+	Configuration.Reset()
+}
+
+// NConfig returns the number of configs that have been set.
+func (f *ConfigSet) NConfig() int {
+	if f.owner != nil {
+		n := 0
+		f.Visit(func(*Config) { n++ })
+		return n
+	}
+	return len(f.actual)
+}
+
+// NConfig returns the number of command-line configs that have been set.
+func NConfig() int { return len(Configuration.actual) }
+
+// Arg returns the i'th argument. Arg(0) is the first remaining argument
+// after configs have been processed. Arg returns an empty string if the
+// requested element does not exist.
+func (f *ConfigSet) Arg(i int) string {
+	if f.owner != nil {
+		return f.owner.Arg(i)
+	}
+	if i < 0 || i >= len(f.args) {
+		return ""
+	}
+	return f.args[i]
+}
+
+// Arg returns the i'th command-line argument. Arg(0) is the first remaining argument
+// after configs have been processed. Arg returns an empty string if the
+// requested element does not exist.
+func Arg(i int) string {
+	return Configuration.Arg(i)
+}
+
+// NArg is the number of arguments remaining after configs have been processed.
+func (f *ConfigSet) NArg() int {
+	if f.owner != nil {
+		return f.owner.NArg()
+	}
+	return len(f.args)
+}
+
+// NArg is the number of arguments remaining after configs have been processed.
+func NArg() int { return len(Configuration.args) }
+
+// Args returns the non-config arguments.
+func (f *ConfigSet) Args() []string {
+	if f.owner != nil {
+		return f.owner.Args()
+	}
+	return f.args
+}
+
+// Args returns the non-config command-line arguments.
+func Args() []string { return Configuration.args }
 
 // BoolVar defines a bool config with specified name, default value, and usage string.
 // The argument p points to a bool variable in which to store the value of the config.
@@ -351,351 +1897,2266 @@ func (f *ConfigSet) BoolVar(p *bool, name string, value bool, usage string) {
 	f.Var(newBoolValue(value, p), name, usage)
 }
 
-// BoolVar defines a bool config with specified name, default value, and usage string.
-// The argument p points to a bool variable in which to store the value of the config.
-func BoolVar(p *bool, name string, value bool, usage string) {
-	Configuration.Var(newBoolValue(value, p), name, usage)
+// BoolVar defines a bool config with specified name, default value, and usage string.
+// The argument p points to a bool variable in which to store the value of the config.
+func BoolVar(p *bool, name string, value bool, usage string) {
+	Configuration.Var(newBoolValue(value, p), name, usage)
+}
+
+// Bool defines a bool config with specified name, default value, and usage string.
+// The return value is the address of a bool variable that stores the value of the config.
+func (f *ConfigSet) Bool(name string, value bool, usage string) *bool {
+	p := new(bool)
+	f.BoolVar(p, name, value, usage)
+	return p
+}
+
+// Bool defines a bool config with specified name, default value, and usage string.
+// The return value is the address of a bool variable that stores the value of the config.
+func Bool(name string, value bool, usage string) *bool {
+	return Configuration.Bool(name, value, usage)
+}
+
+// IntVar defines an int config with specified name, default value, and usage string.
+// The argument p points to an int variable in which to store the value of the config.
+func (f *ConfigSet) IntVar(p *int, name string, value int, usage string) {
+	f.Var(newIntValue(value, p), name, usage)
+}
+
+// IntVar defines an int config with specified name, default value, and usage string.
+// The argument p points to an int variable in which to store the value of the config.
+func IntVar(p *int, name string, value int, usage string) {
+	Configuration.Var(newIntValue(value, p), name, usage)
+}
+
+// Int defines an int config with specified name, default value, and usage string.
+// The return value is the address of an int variable that stores the value of the config.
+func (f *ConfigSet) Int(name string, value int, usage string) *int {
+	p := new(int)
+	f.IntVar(p, name, value, usage)
+	return p
+}
+
+// Int defines an int config with specified name, default value, and usage string.
+// The return value is the address of an int variable that stores the value of the config.
+func Int(name string, value int, usage string) *int {
+	return Configuration.Int(name, value, usage)
+}
+
+// Int64Var defines an int64 config with specified name, default value, and usage string.
+// The argument p points to an int64 variable in which to store the value of the config.
+func (f *ConfigSet) Int64Var(p *int64, name string, value int64, usage string) {
+	f.Var(newInt64Value(value, p), name, usage)
+}
+
+// Int64Var defines an int64 config with specified name, default value, and usage string.
+// The argument p points to an int64 variable in which to store the value of the config.
+func Int64Var(p *int64, name string, value int64, usage string) {
+	Configuration.Var(newInt64Value(value, p), name, usage)
+}
+
+// Int64 defines an int64 config with specified name, default value, and usage string.
+// The return value is the address of an int64 variable that stores the value of the config.
+func (f *ConfigSet) Int64(name string, value int64, usage string) *int64 {
+	p := new(int64)
+	f.Int64Var(p, name, value, usage)
+	return p
+}
+
+// Int64 defines an int64 config with specified name, default value, and usage string.
+// The return value is the address of an int64 variable that stores the value of the config.
+func Int64(name string, value int64, usage string) *int64 {
+	return Configuration.Int64(name, value, usage)
+}
+
+// Int32Var defines an int32 config with specified name, default value, and usage string.
+// The argument p points to an int32 variable in which to store the value of the config.
+func (f *ConfigSet) Int32Var(p *int32, name string, value int32, usage string) {
+	f.Var(newInt32Value(value, p), name, usage)
+}
+
+// Int32Var defines an int32 config with specified name, default value, and usage string.
+// The argument p points to an int32 variable in which to store the value of the config.
+func Int32Var(p *int32, name string, value int32, usage string) {
+	Configuration.Var(newInt32Value(value, p), name, usage)
+}
+
+// Int32 defines an int32 config with specified name, default value, and usage string.
+// The return value is the address of an int32 variable that stores the value of the config.
+func (f *ConfigSet) Int32(name string, value int32, usage string) *int32 {
+	p := new(int32)
+	f.Int32Var(p, name, value, usage)
+	return p
+}
+
+// Int32 defines an int32 config with specified name, default value, and usage string.
+// The return value is the address of an int32 variable that stores the value of the config.
+func Int32(name string, value int32, usage string) *int32 {
+	return Configuration.Int32(name, value, usage)
+}
+
+// UintVar defines a uint config with specified name, default value, and usage string.
+// The argument p points to a uint variable in which to store the value of the config.
+func (f *ConfigSet) UintVar(p *uint, name string, value uint, usage string) {
+	f.Var(newUintValue(value, p), name, usage)
+}
+
+// UintVar defines a uint config with specified name, default value, and usage string.
+// The argument p points to a uint  variable in which to store the value of the config.
+func UintVar(p *uint, name string, value uint, usage string) {
+	Configuration.Var(newUintValue(value, p), name, usage)
+}
+
+// Uint defines a uint config with specified name, default value, and usage string.
+// The return value is the address of a uint  variable that stores the value of the config.
+func (f *ConfigSet) Uint(name string, value uint, usage string) *uint {
+	p := new(uint)
+	f.UintVar(p, name, value, usage)
+	return p
+}
+
+// Uint defines a uint config with specified name, default value, and usage string.
+// The return value is the address of a uint  variable that stores the value of the config.
+func Uint(name string, value uint, usage string) *uint {
+	return Configuration.Uint(name, value, usage)
+}
+
+// Uint64Var defines a uint64 config with specified name, default value, and usage string.
+// The argument p points to a uint64 variable in which to store the value of the config.
+func (f *ConfigSet) Uint64Var(p *uint64, name string, value uint64, usage string) {
+	f.Var(newUint64Value(value, p), name, usage)
+}
+
+// Uint64Var defines a uint64 config with specified name, default value, and usage string.
+// The argument p points to a uint64 variable in which to store the value of the config.
+func Uint64Var(p *uint64, name string, value uint64, usage string) {
+	Configuration.Var(newUint64Value(value, p), name, usage)
+}
+
+// Uint64 defines a uint64 config with specified name, default value, and usage string.
+// The return value is the address of a uint64 variable that stores the value of the config.
+func (f *ConfigSet) Uint64(name string, value uint64, usage string) *uint64 {
+	p := new(uint64)
+	f.Uint64Var(p, name, value, usage)
+	return p
+}
+
+// Uint64 defines a uint64 config with specified name, default value, and usage string.
+// The return value is the address of a uint64 variable that stores the value of the config.
+func Uint64(name string, value uint64, usage string) *uint64 {
+	return Configuration.Uint64(name, value, usage)
+}
+
+// Uint32Var defines a uint32 config with specified name, default value, and usage string.
+// The argument p points to a uint32 variable in which to store the value of the config.
+func (f *ConfigSet) Uint32Var(p *uint32, name string, value uint32, usage string) {
+	f.Var(newUint32Value(value, p), name, usage)
+}
+
+// Uint32Var defines a uint32 config with specified name, default value, and usage string.
+// The argument p points to a uint32 variable in which to store the value of the config.
+func Uint32Var(p *uint32, name string, value uint32, usage string) {
+	Configuration.Var(newUint32Value(value, p), name, usage)
+}
+
+// Uint32 defines a uint32 config with specified name, default value, and usage string.
+// The return value is the address of a uint32 variable that stores the value of the config.
+func (f *ConfigSet) Uint32(name string, value uint32, usage string) *uint32 {
+	p := new(uint32)
+	f.Uint32Var(p, name, value, usage)
+	return p
+}
+
+// Uint32 defines a uint32 config with specified name, default value, and usage string.
+// The return value is the address of a uint32 variable that stores the value of the config.
+func Uint32(name string, value uint32, usage string) *uint32 {
+	return Configuration.Uint32(name, value, usage)
+}
+
+// StringVar defines a string config with specified name, default value, and usage string.
+// The argument p points to a string variable in which to store the value of the config.
+func (f *ConfigSet) StringVar(p *string, name string, value string, usage string) {
+	f.Var(newStringValue(value, p), name, usage)
+}
+
+// StringVar defines a string config with specified name, default value, and usage string.
+// The argument p points to a string variable in which to store the value of the config.
+func StringVar(p *string, name string, value string, usage string) {
+	Configuration.Var(newStringValue(value, p), name, usage)
+}
+
+// String defines a string config with specified name, default value, and usage string.
+// The return value is the address of a string variable that stores the value of the config.
+func (f *ConfigSet) String(name string, value string, usage string) *string {
+	p := new(string)
+	f.StringVar(p, name, value, usage)
+	return p
+}
+
+// String defines a string config with specified name, default value, and usage string.
+// The return value is the address of a string variable that stores the value of the config.
+func String(name string, value string, usage string) *string {
+	return Configuration.String(name, value, usage)
+}
+
+// Float64Var defines a float64 config with specified name, default value, and usage string.
+// The argument p points to a float64 variable in which to store the value of the config.
+func (f *ConfigSet) Float64Var(p *float64, name string, value float64, usage string) {
+	f.Var(newFloat64Value(value, p), name, usage)
+}
+
+// Float64Var defines a float64 config with specified name, default value, and usage string.
+// The argument p points to a float64 variable in which to store the value of the config.
+func Float64Var(p *float64, name string, value float64, usage string) {
+	Configuration.Var(newFloat64Value(value, p), name, usage)
+}
+
+// Float64 defines a float64 config with specified name, default value, and usage string.
+// The return value is the address of a float64 variable that stores the value of the config.
+func (f *ConfigSet) Float64(name string, value float64, usage string) *float64 {
+	p := new(float64)
+	f.Float64Var(p, name, value, usage)
+	return p
+}
+
+// Float64 defines a float64 config with specified name, default value, and usage string.
+// The return value is the address of a float64 variable that stores the value of the config.
+func Float64(name string, value float64, usage string) *float64 {
+	return Configuration.Float64(name, value, usage)
+}
+
+// Float32Var defines a float32 config with specified name, default value, and usage string.
+// The argument p points to a float32 variable in which to store the value of the config.
+func (f *ConfigSet) Float32Var(p *float32, name string, value float32, usage string) {
+	f.Var(newFloat32Value(value, p), name, usage)
+}
+
+// Float32Var defines a float32 config with specified name, default value, and usage string.
+// The argument p points to a float32 variable in which to store the value of the config.
+func Float32Var(p *float32, name string, value float32, usage string) {
+	Configuration.Var(newFloat32Value(value, p), name, usage)
+}
+
+// Float32 defines a float32 config with specified name, default value, and usage string.
+// The return value is the address of a float32 variable that stores the value of the config.
+func (f *ConfigSet) Float32(name string, value float32, usage string) *float32 {
+	p := new(float32)
+	f.Float32Var(p, name, value, usage)
+	return p
+}
+
+// Float32 defines a float32 config with specified name, default value, and usage string.
+// The return value is the address of a float32 variable that stores the value of the config.
+func Float32(name string, value float32, usage string) *float32 {
+	return Configuration.Float32(name, value, usage)
+}
+
+// DurationVar defines a time.Duration config with specified name, default value, and usage string.
+// The argument p points to a time.Duration variable in which to store the value of the config.
+// The config accepts a value acceptable to time.ParseDuration.
+func (f *ConfigSet) DurationVar(p *time.Duration, name string, value time.Duration, usage string) {
+	f.Var(newDurationValue(value, p), name, usage)
+}
+
+// DurationVar defines a time.Duration config with specified name, default value, and usage string.
+// The argument p points to a time.Duration variable in which to store the value of the config.
+// The config accepts a value acceptable to time.ParseDuration.
+func DurationVar(p *time.Duration, name string, value time.Duration, usage string) {
+	Configuration.Var(newDurationValue(value, p), name, usage)
+}
+
+// Duration defines a time.Duration config with specified name, default value, and usage string.
+// The return value is the address of a time.Duration variable that stores the value of the config.
+// The config accepts a value acceptable to time.ParseDuration.
+func (f *ConfigSet) Duration(name string, value time.Duration, usage string) *time.Duration {
+	p := new(time.Duration)
+	f.DurationVar(p, name, value, usage)
+	return p
+}
+
+// Duration defines a time.Duration config with specified name, default value, and usage string.
+// The return value is the address of a time.Duration variable that stores the value of the config.
+// The config accepts a value acceptable to time.ParseDuration.
+func Duration(name string, value time.Duration, usage string) *time.Duration {
+	return Configuration.Duration(name, value, usage)
+}
+
+// Var defines a config with the specified name and usage string. The type and
+// value of the config are represented by the first argument, of type Value, which
+// typically holds a user-defined implementation of Value. For instance, the
+// caller could create a config that turns a comma-separated string into a slice
+// of strings by giving the slice the methods of Value; in particular, Set would
+// decompose the comma-separated string into the slice.
+//
+// Defining a name that is already in use panics, unless the error handling
+// policy is ContinueOnError, in which case the error is printed to the
+// output and the earlier definition is kept. value is then not bound to
+// any config: Set, Parse and Load never change it, and the variable that a
+// function such as Int returns keeps its default value. Use TryVar, which
+// returns the error, to detect the conflict.
+func (f *ConfigSet) Var(value Value, name string, usage string) {
+	if f.owner != nil {
+		f.owner.Var(value, f.prefix+name, usage)
+		return
+	}
+	if err := f.TryVar(value, name, usage); err != nil {
+		fmt.Fprintln(f.Output(), err)
+		if f.errorHandling == ContinueOnError {
+			return
+		}
+		panic(err.Error()) // Happens only if configs are declared with identical names
+	}
+}
+
+// TryVar is like Var but returns an error instead of panicking if name is
+// already in use.
+func (f *ConfigSet) TryVar(value Value, name string, usage string) error {
+	if f.owner != nil {
+		return f.owner.TryVar(value, f.prefix+name, usage)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.define(value, name, usage)
+}
+
+// define defines a config as TryVar does. The caller must hold f.mu.
+func (f *ConfigSet) define(value Value, name string, usage string) error {
+	if f.frozen {
+		return ErrFrozen
+	}
+	// Remember the default value as a string; it won't change.
+	config := &Config{name, usage, value, value.String()}
+	_, alreadythere := f.formal[f.defined(name)]
+	if _, ok := f.aliases[f.defined(name)]; ok {
+		alreadythere = true
+	}
+	if alreadythere {
+		if f.filename == "" {
+			return fmt.Errorf("config redefined: %s", name)
+		}
+		return fmt.Errorf("%s config redefined: %s", f.filename, name)
+	}
+	if f.formal == nil {
+		f.formal = make(map[string]*Config)
+	}
+	f.formal[name] = config
+	f.order = append(f.order, name)
+	f.fold(name)
+	return nil
+}
+
+// Var defines a config with the specified name and usage string. The type and
+// value of the config are represented by the first argument, of type Value, which
+// typically holds a user-defined implementation of Value. For instance, the
+// caller could create a config that turns a comma-separated string into a slice
+// of strings by giving the slice the methods of Value; in particular, Set would
+// decompose the comma-separated string into the slice.
+func Var(value Value, name string, usage string) {
+	Configuration.Var(value, name, usage)
+}
+
+// TryVar is like Var but returns an error instead of panicking if name is
+// already in use.
+func TryVar(value Value, name string, usage string) error {
+	return Configuration.TryVar(value, name, usage)
+}
+
+// usage calls the Usage method for the config set if one is specified,
+// or the appropriate default usage function otherwise.
+func (f *ConfigSet) usage() {
+	if f.Usage == nil {
+		f.defaultUsage()
+	} else {
+		f.Usage()
+	}
+}
+
+// defaultUsage prints a usage message listing the configs to the output.
+func (f *ConfigSet) defaultUsage() {
+	if f.name != "" {
+		fmt.Fprintf(f.Output(), "Usage of %s:\n", f.name)
+	} else if f.filename == "" {
+		fmt.Fprintf(f.Output(), "Usage:\n")
+	} else {
+		fmt.Fprintf(f.Output(), "Usage of %s:\n", f.filename)
+	}
+	f.PrintDefaults(f.Output())
+}
+
+// failf prints to the output a formatted error and returns the error.
+func (f *ConfigSet) failf(format string, a ...interface{}) error {
+	err := fmt.Errorf(format, a...)
+	fmt.Fprintln(f.Output(), err)
+	return err
+}
+
+// handleError applies the error handling policy of the config set to err.
+// It returns err under ContinueOnError and does not return otherwise.
+func (f *ConfigSet) handleError(err error) error {
+	switch f.errorHandling {
+	case ExitOnError:
+		if err == ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	case PanicOnError:
+		panic(err)
+	}
+	return err
+}
+
+// errSection returns ErrSection, or panics with it under PanicOnError, for
+// the methods that return an error and cannot act on a section.
+func (f *ConfigSet) errSection() error {
+	if f.errorHandling == PanicOnError {
+		panic(ErrSection)
+	}
+	return ErrSection
+}
+
+// checkNotSection panics if f is a section, for the methods that cannot act
+// on a section and do not return an error.
+func (f *ConfigSet) checkNotSection(method string) {
+	if f.owner != nil {
+		panic(fmt.Sprintf("config: %s called on section %s", method, strings.TrimSuffix(f.prefix, ".")))
+	}
+}
+
+// parseOne parses one config. It reports whether a config was seen.
+func (f *ConfigSet) parseOne() (bool, error) {
+	if len(f.args) == 0 {
+		return false, nil
+	}
+	s := f.args[0]
+	if len(s) < 2 || s[0] != '-' {
+		return false, nil
+	}
+	numMinuses := 1
+	if s[1] == '-' {
+		numMinuses++
+		if len(s) == 2 { // "--" terminates the configs
+			f.args = f.args[1:]
+			return false, nil
+		}
+	}
+	name := s[numMinuses:]
+	if len(name) == 0 || name[0] == '-' || name[0] == '=' {
+		return false, f.failf("bad config syntax: %s", s)
+	}
+
+	// it's a config. does it have an argument?
+	f.args = f.args[1:]
+	hasValue := false
+	value := ""
+	for i := 1; i < len(name); i++ { // equals cannot be first
+		if name[i] == '=' {
+			value = name[i+1:]
+			hasValue = true
+			name = name[0:i]
+			break
+		}
+	}
+	target, config := f.find(name)
+	alreadythere := config != nil
+	if !alreadythere && f.EnableNegation && strings.HasPrefix(name, "no-") {
+		if negTarget, negated := f.find(name[3:]); negated != nil {
+			if _, ok := getValue(negated.Value).(bool); ok {
+				if hasValue {
+					return false, f.failf("config does not take a value: -%s", name)
+				}
+				if err := f.setIn(negTarget, name[3:], "false"); err != nil {
+					return false, f.failf("invalid value %q for config -%s: %w", "false", name[3:], err)
+				}
+				return true, nil
+			}
+		}
+	}
+	if !alreadythere && f.AllowPrefixMatch && name != "help" && name != "h" {
+		full, err := f.matchPrefix(name)
+		if err != nil {
+			return false, f.failf("%w", err)
+		}
+		if full != "" {
+			name = full
+			target, config = f.find(name)
+			alreadythere = true
+		}
+	}
+	if !alreadythere {
+		if name == "help" || name == "h" { // special case for nice help message.
+			return false, ErrHelp
+		}
+		return false, f.failf("%w: -%s", ErrUnknownConfig, name)
+	}
+	if fv, ok := config.Value.(boolConfig); ok && fv.IsBoolConfig() { // special case: doesn't need an arg
+		if !hasValue {
+			value = "true"
+		}
+	} else {
+		// It must have a value, which might be the next argument.
+		if !hasValue && len(f.args) > 0 {
+			// value is the next arg
+			hasValue = true
+			value, f.args = f.args[0], f.args[1:]
+		}
+		if !hasValue {
+			return false, f.failf("config needs an argument: -%s", name)
+		}
+	}
+	if err := f.setIn(target, name, value); err != nil {
+		return false, f.failf("invalid value %q for config -%s: %w", value, name, err)
+	}
+	return true, nil
+}
+
+// setIn sets the named config in target, which is f or a parent of f, for
+// Parse. The caller must hold f.mu.
+func (f *ConfigSet) setIn(target *ConfigSet, name, value string) error {
+	if target == f {
+		return f.setFrom(name, value, "command-line")
+	}
+	target.mu.Lock()
+	defer target.unlock()
+	return target.setFrom(name, value, "command-line")
+}
+
+// Parse parses config definitions from the argument list, which should not
+// include the command name. Must be called after all configs in the ConfigSet
+// are defined and before configs are accessed by the program.
+// The arguments left after the configs are available from Args. A config
+// that is not defined is an error that wraps ErrUnknownConfig and is
+// followed by the usage message. If -help or -h is given but not defined,
+// Parse prints the usage message and returns ErrHelp. Parse depends only
+// on f and arguments: it does not read os.Args or use the command-line
+// config set, so a ConfigSet can be parsed in isolation.
+func (f *ConfigSet) Parse(arguments []string) error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	f.mu.Lock()
+	f.parsed = true
+	f.args = arguments
+	f.selected = nil
+	for {
+		seen, err := f.parseOne()
+		if seen {
+			continue
+		}
+		if err == nil {
+			break
+		}
+		f.unlock()
+		// Usage may call methods of f, so it runs with f unlocked.
+		if err == ErrHelp || errors.Is(err, ErrUnknownConfig) {
+			f.usage()
+		}
+		return f.handleError(err)
+	}
+	if len(f.args) > 0 {
+		f.selected = f.subcommands[f.args[0]]
+	}
+	sub := f.selected
+	args := f.args
+	f.unlock()
+	if sub != nil {
+		return sub.Parse(args[1:])
+	}
+	return nil
+}
+
+// Parse parses the command-line configs from os.Args[1:]. Must be called
+// after all configs are defined and before configs are accessed by the program.
+// Errors are handled according to the error handling policy of
+// Configuration, which exits by default; under ContinueOnError, as a set
+// installed by SetDefault may have, Parse returns them.
+func Parse() error {
+	return Configuration.Parse(os.Args[1:])
+}
+
+// ParseString splits s into arguments as a shell would and parses them as
+// Parse does. Arguments are separated by unquoted spaces, tabs and
+// newlines. Text between single quotes is taken literally. Between double
+// quotes, a backslash escapes a '"' or another backslash and stands for
+// itself before any other character. Elsewhere a backslash makes the next
+// character, such as a space or quote, literal. An unterminated quote or a
+// trailing backslash is an error, handled according to the error handling
+// policy.
+func (f *ConfigSet) ParseString(s string) error {
+	args, err := splitArgs(s)
+	if err != nil {
+		return f.handleError(f.failf("%w", err))
+	}
+	return f.Parse(args)
+}
+
+// ParseString parses the command-line configs from the arguments in s.
+func ParseString(s string) error {
+	return Configuration.ParseString(s)
+}
+
+// splitArgs splits s into arguments by the rules described for ParseString.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var b strings.Builder
+	inArg := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+			continue
+		case c == '\\':
+			i++
+			if i == len(s) {
+				return nil, errors.New("trailing backslash in arguments")
+			}
+			b.WriteByte(s[i])
+		case c == '\'':
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return nil, errors.New("unterminated single quote in arguments")
+			}
+			b.WriteString(s[i+1 : i+1+j])
+			i += j + 1
+		case c == '"':
+			for i++; ; i++ {
+				if i == len(s) {
+					return nil, errors.New("unterminated double quote in arguments")
+				}
+				if s[i] == '"' {
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+		inArg = true
+	}
+	if inArg {
+		args = append(args, b.String())
+	}
+	return args, nil
+}
+
+// Freeze makes f read-only until Unfreeze is called. While it is frozen,
+// Set, Var, Load and the other methods that would change a config or define
+// one return ErrFrozen instead, or panic with it under PanicOnError, and
+// Parse reports it according to the error handling policy. Configs can
+// still be read as usual. A copy made by Clone is not frozen.
+func (f *ConfigSet) Freeze() {
+	if f.owner != nil {
+		f.owner.Freeze()
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frozen = true
+}
+
+// Unfreeze makes f, frozen by Freeze, changeable again.
+func (f *ConfigSet) Unfreeze() {
+	if f.owner != nil {
+		f.owner.Unfreeze()
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frozen = false
+}
+
+// Frozen reports whether f has been frozen by Freeze.
+func (f *ConfigSet) Frozen() bool {
+	if f.owner != nil {
+		return f.owner.Frozen()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.frozen
+}
+
+// errFrozen returns ErrFrozen, or panics with it under PanicOnError.
+func (f *ConfigSet) errFrozen() error {
+	if f.errorHandling == PanicOnError {
+		panic(ErrFrozen)
+	}
+	return ErrFrozen
+}
+
+// Freeze makes the command-line config set read-only.
+func Freeze() {
+	Configuration.Freeze()
+}
+
+// Unfreeze makes the command-line config set changeable again.
+func Unfreeze() {
+	Configuration.Unfreeze()
+}
+
+// Parsed reports whether f.Parse has been called.
+func (f *ConfigSet) Parsed() bool {
+	if f.owner != nil {
+		return f.owner.Parsed()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.parsed
+}
+
+// Parsed reports whether the command-line configs have been parsed.
+func Parsed() bool {
+	return Configuration.Parsed()
+}
+
+// Merge sets each config that has been set in other to the same value in f.
+// A config already set in f is only changed if overwrite is true. Configs
+// that are not defined in f are handled as by Set. Merge returns the errors
+// from setting any of the values.
+func (f *ConfigSet) Merge(other *ConfigSet, overwrite bool) error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	other.mu.Lock()
+	values := make(map[string]string, len(other.actual))
+	sources := make(map[string]string, len(other.actual))
+	for name, config := range other.actual {
+		values[name] = config.Value.String()
+		sources[name] = other.sources[name]
+	}
+	other.mu.Unlock()
+
+	f.mu.Lock()
+	defer f.unlock()
+	var errs []error
+	for _, name := range sortedKeys(values) {
+		if _, ok := f.actual[name]; ok && !overwrite {
+			continue
+		}
+		if err := f.set(name, values[name], setReplace); err != nil {
+			errs = append(errs, fmt.Errorf("config %s: %w", name, err))
+			continue
+		}
+		if sources[name] != "" {
+			if f.sources == nil {
+				f.sources = make(map[string]string)
+			}
+			f.sources[f.canonical(name)] = sources[name]
+		} else {
+			delete(f.sources, f.canonical(name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Clone returns a copy of f, with the same file name, options, validators
+// and configs, that can be changed independently of f. Each config in the
+// copy has a new Value of the same type set to the String form of the
+// original, so a custom Value type must be a pointer whose zero value can
+// be Set, and its String and Set must round-trip, for the copy to be
+// accurate; Clone panics if a Value is of a type it cannot make, such as a
+// custom Value that is not a pointer. A copy of a subcommand has copies of
+// the configs it inherits from its parent as its own, rather than sharing
+// them. OnChange and OnReload functions and subcommands are not copied,
+// and the copy has the default Usage.
+func (f *ConfigSet) Clone() *ConfigSet {
+	f.checkNotSection("Clone")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := &ConfigSet{
+		PreserveLayout:   f.PreserveLayout,
+		ExpandEnv:        f.ExpandEnv,
+		StrictEnv:        f.StrictEnv,
+		ExpandConfigs:    f.ExpandConfigs,
+		AllowUnknown:     f.AllowUnknown,
+		RejectDisallowed: f.RejectDisallowed,
+		RequireFiles:     f.RequireFiles,
+		AllowPrefixMatch: f.AllowPrefixMatch,
+		HideDeprecated:   f.HideDeprecated,
+		CaseInsensitive:  f.CaseInsensitive,
+		KeyNormalizer:    f.KeyNormalizer,
+		SaveOrder:        f.SaveOrder,
+		CommentChars:     f.CommentChars,
+		AlignValues:      f.AlignValues,
+		CommentDefaults:  f.CommentDefaults,
+		RawQuotes:        f.RawQuotes,
+		RedactSecrets:    f.RedactSecrets,
+		EnableNegation:   f.EnableNegation,
+		AllowFileRefs:    f.AllowFileRefs,
+		LenientBool:      f.LenientBool,
+		filename:         f.filename,
+		parsed:           f.parsed,
+		args:             append([]string(nil), f.args...),
+		errorHandling:    f.errorHandling,
+		output:           f.output,
+		logger:           f.logger,
+		layout:           append([]layoutLine(nil), f.layout...),
+		envPrefix:        f.envPrefix,
+		order:            append([]string(nil), f.order...),
+		name:             f.name,
+	}
+	for name, envVar := range f.env {
+		c.BindEnv(name, envVar)
+	}
+	for alias, canonical := range f.aliases {
+		if c.aliases == nil {
+			c.aliases = make(map[string]string)
+		}
+		c.aliases[alias] = canonical
+	}
+	for lower, name := range f.folded {
+		if c.folded == nil {
+			c.folded = make(map[string]string)
+		}
+		c.folded[lower] = name
+	}
+	for name, tags := range f.tags {
+		if c.tags == nil {
+			c.tags = make(map[string][]string)
+		}
+		c.tags[name] = append([]string(nil), tags...)
+	}
+	for name, source := range f.sources {
+		if c.sources == nil {
+			c.sources = make(map[string]string)
+		}
+		c.sources[name] = source
+	}
+	for name := range f.secrets {
+		if c.secrets == nil {
+			c.secrets = make(map[string]bool)
+		}
+		c.secrets[name] = true
+	}
+	for name, msg := range f.deprecated {
+		if c.deprecated == nil {
+			c.deprecated = make(map[string]string)
+		}
+		c.deprecated[name] = msg
+	}
+	for name, fn := range f.validators {
+		if c.validators == nil {
+			c.validators = make(map[string]func(Value) error)
+		}
+		c.validators[name] = fn
+	}
+	// Copy the configs of f and then those it inherits, unless a nearer
+	// set defines the same name.
+	for s := f; s != nil; s = s.parent {
+		if s != f {
+			s.mu.Lock()
+		}
+		for name, config := range s.formal {
+			if _, ok := c.formal[name]; ok {
+				continue
+			}
+			value := newValue(config.Value)
+			if value == nil {
+				panic(fmt.Sprintf("config %s: cannot copy Value of type %T", name, config.Value))
+			}
+			resetValue(value, config.Value.String())
+			if c.formal == nil {
+				c.formal = make(map[string]*Config)
+			}
+			c.formal[name] = &Config{config.Name, config.Usage, value, config.DefValue}
+			if _, ok := s.actual[name]; ok {
+				if c.actual == nil {
+					c.actual = make(map[string]*Config)
+				}
+				c.actual[name] = c.formal[name]
+			}
+		}
+		if s != f {
+			s.mu.Unlock()
+		}
+	}
+	return c
 }
 
-// Bool defines a bool config with specified name, default value, and usage string.
-// The return value is the address of a bool variable that stores the value of the config.
-func (f *ConfigSet) Bool(name string, value bool, usage string) *bool {
-	p := new(bool)
-	f.BoolVar(p, name, value, usage)
-	return p
+// Unset removes the named config, its aliases, its deprecation, its
+// validator and its OnChange functions from the set, so that the name can
+// be defined again, possibly with a different type. If name is an alias,
+// only the alias is removed. Pointers and Values obtained for the config
+// before it was removed still work but are no longer connected to the set.
+// It is an error to remove a config from a frozen set.
+func (f *ConfigSet) Unset(name string) error {
+	if f.owner != nil {
+		return f.owner.Unset(f.prefix + name)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.frozen {
+		return f.errFrozen()
+	}
+	name = f.defined(name)
+	if _, ok := f.aliases[name]; ok {
+		delete(f.aliases, name)
+		delete(f.deprecated, name)
+		f.unfold(name)
+		return nil
+	}
+	for alias, canonical := range f.aliases {
+		if canonical == name {
+			delete(f.aliases, alias)
+			delete(f.deprecated, alias)
+			f.unfold(alias)
+		}
+	}
+	f.unfold(name)
+	for i, n := range f.order {
+		if n == name {
+			f.order = append(f.order[:i], f.order[i+1:]...)
+			break
+		}
+	}
+	delete(f.deprecated, name)
+	delete(f.formal, name)
+	delete(f.actual, name)
+	delete(f.validators, name)
+	delete(f.changeFuncs, name)
+	delete(f.secrets, name)
+	delete(f.sources, name)
+	delete(f.tags, name)
+	return nil
 }
 
-// Bool defines a bool config with specified name, default value, and usage string.
-// The return value is the address of a bool variable that stores the value of the config.
-func Bool(name string, value bool, usage string) *bool {
-	return Configuration.Bool(name, value, usage)
+// Unset removes the named command-line config.
+func Unset(name string) error {
+	return Configuration.Unset(name)
 }
 
-// IntVar defines an int config with specified name, default value, and usage string.
-// The argument p points to an int variable in which to store the value of the config.
-func (f *ConfigSet) IntVar(p *int, name string, value int, usage string) {
-	f.Var(newIntValue(value, p), name, usage)
+// Configuration is the default set of command-line configs, parsed from os.Args.
+// The top-level functions such as BoolVar, Arg, and so on are wrappers for the
+// methods of Configuration.
+var Configuration = NewConfigSet("", ExitOnError)
+
+func init() {
+	// Override generic ConfigSet default Usage with call to global Usage.
+	Configuration.Usage = commandLineUsage
 }
 
-// IntVar defines an int config with specified name, default value, and usage string.
-// The argument p points to an int variable in which to store the value of the config.
-func IntVar(p *int, name string, value int, usage string) {
-	Configuration.Var(newIntValue(value, p), name, usage)
+// SetDefault makes cs the set of command-line configs that the top-level
+// functions use, replacing Configuration, and returns the set it replaces.
+// It lets a test install a fresh set and restore the original when it is
+// done:
+//
+//	defer config.SetDefault(config.SetDefault(config.NewConfigSet("", config.ContinueOnError)))
+//
+// SetDefault is not safe to call while other goroutines use the top-level
+// functions or Configuration, and pointers to configs defined in the
+// replaced set stay connected to that set and not to cs. Unlike
+// Configuration, cs keeps its own Usage function rather than calling the
+// package-level Usage.
+func SetDefault(cs *ConfigSet) *ConfigSet {
+	old := Configuration
+	Configuration = cs
+	return old
 }
 
-// Int defines an int config with specified name, default value, and usage string.
-// The return value is the address of an int variable that stores the value of the config.
-func (f *ConfigSet) Int(name string, value int, usage string) *int {
-	p := new(int)
-	f.IntVar(p, name, value, usage)
-	return p
+// Usage prints a usage message documenting all defined command-line configs
+// to the output of Configuration. It is called when Parse sees -h or -help.
+// The function is a variable that may be changed to point to a custom
+// function.
+var Usage = func() {
+	fmt.Fprintf(Configuration.Output(), "Usage of %s:\n", os.Args[0])
+	PrintDefaults(Configuration.Output())
 }
 
-// Int defines an int config with specified name, default value, and usage string.
-// The return value is the address of an int variable that stores the value of the config.
-func Int(name string, value int, usage string) *int {
-	return Configuration.Int(name, value, usage)
+func commandLineUsage() {
+	Usage()
 }
 
-// Int64Var defines an int64 config with specified name, default value, and usage string.
-// The argument p points to an int64 variable in which to store the value of the config.
-func (f *ConfigSet) Int64Var(p *int64, name string, value int64, usage string) {
-	f.Var(newInt64Value(value, p), name, usage)
+// NewConfigSet returns a new, empty config set with the specified name and
+// error handling property.
+func NewConfigSet(filename string, errorHandling ErrorHandling) *ConfigSet {
+	f := &ConfigSet{
+		filename:      filename,
+		errorHandling: errorHandling,
+	}
+	f.Usage = f.defaultUsage
+	return f
 }
 
-// Int64Var defines an int64 config with specified name, default value, and usage string.
-// The argument p points to an int64 variable in which to store the value of the config.
-func Int64Var(p *int64, name string, value int64, usage string) {
-	Configuration.Var(newInt64Value(value, p), name, usage)
+// Subcommand returns the ConfigSet for the named subcommand of f, creating
+// it if needed. When Parse meets the subcommand's name as the first
+// argument after f's configs, it parses the remaining arguments with the
+// subcommand's set. The subcommand inherits f's configs: Lookup, Set and
+// Parse fall through to f for names that the subcommand does not define
+// itself, so a config defined in both is the subcommand's own.
+func (f *ConfigSet) Subcommand(name string) *ConfigSet {
+	f.checkNotSection("Subcommand")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if sub, ok := f.subcommands[name]; ok {
+		return sub
+	}
+	sub := &ConfigSet{
+		name:          name,
+		parent:        f,
+		errorHandling: f.errorHandling,
+		output:        f.output,
+		logger:        f.logger,
+	}
+	sub.Usage = sub.defaultUsage
+	if f.subcommands == nil {
+		f.subcommands = make(map[string]*ConfigSet)
+	}
+	f.subcommands[name] = sub
+	return sub
 }
 
-// Int64 defines an int64 config with specified name, default value, and usage string.
-// The return value is the address of an int64 variable that stores the value of the config.
-func (f *ConfigSet) Int64(name string, value int64, usage string) *int64 {
-	p := new(int64)
-	f.Int64Var(p, name, value, usage)
-	return p
+// Subcommand returns the ConfigSet for the named subcommand of the
+// command-line config set.
+func Subcommand(name string) *ConfigSet {
+	return Configuration.Subcommand(name)
 }
 
-// Int64 defines an int64 config with specified name, default value, and usage string.
-// The return value is the address of an int64 variable that stores the value of the config.
-func Int64(name string, value int64, usage string) *int64 {
-	return Configuration.Int64(name, value, usage)
+// Selected returns the subcommand chosen by the last call to f.Parse, or
+// nil if there was none.
+func (f *ConfigSet) Selected() *ConfigSet {
+	if f.owner != nil {
+		return f.owner.Selected()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.selected
 }
 
-// UintVar defines a uint config with specified name, default value, and usage string.
-// The argument p points to a uint variable in which to store the value of the config.
-func (f *ConfigSet) UintVar(p *uint, name string, value uint, usage string) {
-	f.Var(newUintValue(value, p), name, usage)
+// Selected returns the subcommand chosen by Parse, or nil if there was none.
+func Selected() *ConfigSet {
+	return Configuration.Selected()
 }
 
-// UintVar defines a uint config with specified name, default value, and usage string.
-// The argument p points to a uint  variable in which to store the value of the config.
-func UintVar(p *uint, name string, value uint, usage string) {
-	Configuration.Var(newUintValue(value, p), name, usage)
+// Name returns the name of a subcommand's config set, or "" for any other.
+func (f *ConfigSet) Name() string {
+	if f.owner != nil {
+		return f.owner.Name()
+	}
+	return f.name
 }
 
-// Uint defines a uint config with specified name, default value, and usage string.
-// The return value is the address of a uint  variable that stores the value of the config.
-func (f *ConfigSet) Uint(name string, value uint, usage string) *uint {
-	p := new(uint)
-	f.UintVar(p, name, value, usage)
-	return p
+// Section returns a ConfigSet for defining configs in the named section of f.
+// A config "host" defined on the section "database" is named "database.host"
+// in f and is written by Save under a [database] header; a section of a
+// section adds its name in the same way. The methods of a section that take
+// a config name, such as Var, the typed definition functions, Set, Lookup,
+// Changed and OnChange, take names relative to it, as do SetBatch,
+// ApplyDefaults and ApplySnapshot, and GetAll and Snapshot return them so.
+// The methods that visit, count, validate, reset or list configs, such as
+// VisitAll, Visit, NConfig, Validate, Reset, ResetToDefaults, Print and
+// PrintDefaults, act on the configs of the section only, which they pass to
+// fn under their full names in f. Freeze, Unfreeze and Frozen act on all of
+// f, and Output, Parsed, Args and Name report those of f. The methods that
+// act on a whole config set, such as Parse, Load, Save, Merge, Resolve and
+// Watch, return ErrSection, and SetOutput, SetLogger, Init, SetEnvPrefix,
+// Clone, Diff, Subcommand, OnReload, CopyFromFlagSet and ToFlagSet panic.
+// Options such as AllowUnknown are those of f and have no effect when set on
+// a section.
+func (f *ConfigSet) Section(name string) *ConfigSet {
+	if f.owner != nil {
+		return f.owner.Section(f.prefix + name)
+	}
+	return &ConfigSet{
+		owner:         f,
+		prefix:        name + ".",
+		errorHandling: f.errorHandling,
+	}
+}
+
+// Section returns a ConfigSet for defining configs in the named section of
+// the command-line config set.
+func Section(name string) *ConfigSet {
+	return Configuration.Section(name)
+}
+
+// BindEnv binds the named config to the environment variable envVar, which
+// Resolve uses as a fallback source for the config's value.
+func (f *ConfigSet) BindEnv(name, envVar string) {
+	if f.owner != nil {
+		f.owner.BindEnv(f.prefix+name, envVar)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.env == nil {
+		f.env = make(map[string]string)
+	}
+	f.env[f.canonical(name)] = envVar
+}
+
+// BindEnv binds the named command-line config to the environment variable envVar.
+func BindEnv(name, envVar string) {
+	Configuration.BindEnv(name, envVar)
+}
+
+// SetEnvPrefix makes Resolve fall back on an environment variable for every
+// config that has not been bound with BindEnv. The variable name is the prefix
+// and the config name joined by an underscore, upper-cased, with characters
+// other than letters and digits replaced by underscores: with prefix "app",
+// the config "my/bool_var" is read from APP_MY_BOOL_VAR.
+func (f *ConfigSet) SetEnvPrefix(prefix string) {
+	f.checkNotSection("SetEnvPrefix")
+	f.envPrefix = prefix
+}
+
+// SetEnvPrefix sets the environment variable prefix of the command-line config set.
+func SetEnvPrefix(prefix string) {
+	Configuration.SetEnvPrefix(prefix)
+}
+
+// envName returns the environment variable used for the named config, or
+// "" if there is none.
+func (f *ConfigSet) envName(name string) string {
+	if envVar, ok := f.env[name]; ok {
+		return envVar
+	}
+	if f.envPrefix == "" {
+		return ""
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, f.envPrefix+"_"+name)
+}
+
+// Resolve sets each config that has not been set yet, on the command line
+// or from a file, from its environment variable if that is set. Call it
+// after Load and Parse so that the command line takes precedence over the
+// file, the file over the environment, and the environment over defaults.
+// Values that fail to set are handled according to the error handling policy.
+func (f *ConfigSet) Resolve() error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	f.mu.Lock()
+	defer f.unlock()
+	var errs []error
+	for _, config := range sortConfigs(f.formal) {
+		if _, ok := f.actual[config.Name]; ok {
+			continue
+		}
+		envVar := f.envName(config.Name)
+		if envVar == "" {
+			continue
+		}
+		val, ok := os.LookupEnv(envVar)
+		if !ok {
+			continue
+		}
+		if err := f.setFrom(config.Name, val, "env:"+envVar); err != nil {
+			err = f.failf("invalid value %q for config %s from $%s: %w", val, config.Name, envVar, err)
+			errs = append(errs, f.handleError(err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Resolve sets the unset command-line configs from their environment variables.
+func Resolve() error {
+	return Configuration.Resolve()
+}
+
+// Init sets the name and error handling property for a config set.
+// By default, the zero ConfigSet uses an empty name and the
+// ContinueOnError error handling policy.
+func (f *ConfigSet) Init(filename string, errorHandling ErrorHandling) {
+	f.checkNotSection("Init")
+	f.filename = filename
+	f.errorHandling = errorHandling
+}
+
+// Save writes the configuration to the filename configured in the
+// NewConfigSet function. The file is replaced atomically: the contents are
+// written to a temporary file in the same directory which is then renamed
+// over the destination.
+func (f *ConfigSet) Save() error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	if f.filename == "" {
+		return errors.New("no filename to save")
+	}
+	f.logf("writing config to %s", f.filename)
+	if err := writeFile(f.filename, f.SaveTo); err != nil {
+		return err
+	}
+	f.logf("wrote config to %s", f.filename)
+	return nil
+}
+
+// writeFile atomically replaces the file filename with the output of
+// write, keeping the permissions of the file if it exists.
+func writeFile(filename string, write func(io.Writer) error) (err error) {
+	out, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(filename); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err = out.Chmod(mode); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+
+	if err = write(out); err != nil {
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	if err = out.Close(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	if err = os.Rename(out.Name(), filename); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	return nil
+}
+
+// Update writes the configuration to the named file, which may be shared
+// with other programs, the way Save does with PreserveLayout set: lines
+// setting configs of f are rewritten if their values have changed, configs
+// not in the file are added, and all other lines, including those setting
+// configs that f does not define, are kept as they are. The file is
+// created if it does not exist.
+func (f *ConfigSet) Update(filename string) error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logf("updating config in %s", filename)
+	var layout []layoutLine
+	in, err := os.Open(filename)
+	if err == nil {
+		err = scanLines(in, f.commentChars(), f.RawQuotes, func(l fileLine) {
+			if l.kv {
+				layout = append(layout, layoutLine{l.text, f.canonical(l.key), l.value})
+			} else {
+				layout = append(layout, layoutLine{text: l.text})
+			}
+		})
+		in.Close()
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("updating config: %w", err)
+	}
+	return writeFile(filename, func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		f.writeLayout(bw, layout, f.RedactSecrets)
+		if err := bw.Flush(); err != nil {
+			return fmt.Errorf("updating config: %w", err)
+		}
+		return nil
+	})
+}
+
+// SaveTo writes the configuration to w in the format written by Save.
+func (f *ConfigSet) SaveTo(w io.Writer) error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	return f.saveTo(w, f.RedactSecrets)
+}
+
+// SaveWithSecrets is like Save but writes the values of secret configs
+// even if RedactSecrets is set.
+func (f *ConfigSet) SaveWithSecrets() error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	if f.filename == "" {
+		return errors.New("no filename to save")
+	}
+	f.logf("writing config to %s", f.filename)
+	err := writeFile(f.filename, func(w io.Writer) error {
+		return f.saveTo(w, false)
+	})
+	if err != nil {
+		return err
+	}
+	f.logf("wrote config to %s", f.filename)
+	return nil
+}
+
+// saveTo writes the configuration to w, redacting secrets if redact is true.
+func (f *ConfigSet) saveTo(w io.Writer, redact bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	if f.PreserveLayout && f.layout != nil {
+		f.writeLayout(bw, f.layout, redact)
+	} else {
+		writeConfigs(bw, f.saveConfigs(redact), f.writeOptions())
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	return nil
+}
+
+// SaveTagged writes the configs that have the tag to w in the format
+// written by Save, leaving out the others.
+func (f *ConfigSet) SaveTagged(w io.Writer, tag string) error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var configs []*Config
+	for _, config := range f.saveConfigs(f.RedactSecrets) {
+		if f.hasTag(config.Name, tag) {
+			configs = append(configs, config)
+		}
+	}
+	bw := bufio.NewWriter(w)
+	writeConfigs(bw, configs, f.writeOptions())
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	return nil
+}
+
+// SaveBytes returns the configuration in the format written by Save.
+func (f *ConfigSet) SaveBytes() ([]byte, error) {
+	var b bytes.Buffer
+	if err := f.SaveTo(&b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// formatValue returns val as it should appear in a config file. A value
+// of several lines is written as a """ block when it can be read back as
+// one. Other values that would not read back as they are, such as those
+// with leading or trailing space, a '#', a ';', a '"' or a control
+// character, are written as a Go quoted string, with control characters
+// escaped as \t, \n and so on. If raw is true, such a value is written
+// between double quotes as it is, if it can be read back that way.
+func formatValue(val string, raw bool) string {
+	if strings.Contains(val, "\n") && !strings.ContainsAny(val, "\r") &&
+		!strings.Contains(val, `"""`) && !strings.HasSuffix(val, `"`) {
+		return `"""` + "\n" + val + `"""`
+	}
+	if val != strings.TrimSpace(val) || strings.ContainsAny(val, "#;\"=") ||
+		strings.IndexFunc(val, unicode.IsControl) > -1 || strings.HasSuffix(val, `\`) {
+		if raw && !strings.ContainsAny(val, "\"\n\r") && !strings.HasSuffix(val, `\`) {
+			return `"` + val + `"`
+		}
+		return strconv.Quote(val)
+	}
+	return val
+}
+
+// blockStart returns the index in line of the """ opening a block value,
+// or -1 if line does not open one. chars are the comment characters.
+func blockStart(line, chars string) int {
+	eq := strings.Index(line, "=")
+	if eq < 0 {
+		return -1
+	}
+	if ci := commentIndex(line, chars); ci > -1 && ci < eq {
+		return -1
+	}
+	rest := strings.TrimLeft(line[eq+1:], " \t")
+	if !strings.HasPrefix(rest, `"""`) {
+		return -1
+	}
+	return len(line) - len(rest)
+}
+
+// readBlock reads the rest of the block value opened at index i of text
+// from scanner, advancing *lineno. It returns the lines read, joined by
+// newlines, and the value. The value is the text between the """
+// delimiters exactly, except that a newline right after the opening one is
+// dropped. Anything after the closing """ is a comment.
+func readBlock(scanner *bufio.Scanner, text string, i int, lineno *int) (string, string, error) {
+	rest := text[i+3:]
+	if j := strings.Index(rest, `"""`); j > -1 {
+		return text, rest[:j], nil
+	}
+	var parts []string
+	if rest != "" {
+		parts = append(parts, rest)
+	}
+	for scanner.Scan() {
+		*lineno++
+		next := scanner.Text()
+		text += "\n" + next
+		if j := strings.Index(next, `"""`); j > -1 {
+			return text, strings.Join(append(parts, next[:j]), "\n"), nil
+		}
+		parts = append(parts, next)
+	}
+	return text, "", errors.New(`unterminated """ value`)
+}
+
+// firstLine returns the first line of text, trimmed of space, for use in
+// error messages.
+func firstLine(text string) string {
+	if i := strings.Index(text, "\n"); i > -1 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+// parseValue returns the value written as s in a config file. A value in
+// double quotes is unquoted as a Go string, decoding escape sequences such
+// as \n and \t; if that fails, as it may for a file written by hand, only
+// the quotes are removed. If raw is true, only the quotes are removed unless
+// the text between them contains a '"' or ends in a backslash, which
+// formatValue writes only as a Go quoted string.
+func parseValue(s string, raw bool) string {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	if inner := s[1 : len(s)-1]; raw && !strings.Contains(inner, `"`) && !strings.HasSuffix(inner, `\`) {
+		return inner
+	}
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s[1 : len(s)-1]
+}
+
+// splitSection splits a config name into its section and its key within
+// the section. Names without a dot belong to no section.
+func splitSection(name string) (section, key string) {
+	if i := strings.LastIndex(name, "."); i > -1 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// parseSection reports whether line is a [section] header and returns the
+// section name.
+func parseSection(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if len(line) < 2 || line[0] != '[' || line[len(line)-1] != ']' {
+		return "", false
+	}
+	return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+// groupSections groups configs by section, keeping their order. Sections
+// are listed in order of first appearance, not including the unnamed one.
+func groupSections(configs []*Config) (sections []string, grouped map[string][]*Config) {
+	grouped = make(map[string][]*Config)
+	for _, config := range configs {
+		section, _ := splitSection(config.Name)
+		if _, ok := grouped[section]; !ok && section != "" {
+			sections = append(sections, section)
+		}
+		grouped[section] = append(grouped[section], config)
+	}
+	return sections, grouped
+}
+
+// keyWidth returns the length of the longest key among configs, for
+// aligning them, or 0 if align is false.
+func keyWidth(configs []*Config, align bool) int {
+	width := 0
+	if align {
+		for _, config := range configs {
+			if _, key := splitSection(config.Name); len(key) > width {
+				width = len(key)
+			}
+		}
+	}
+	return width
 }
 
-// Uint defines a uint config with specified name, default value, and usage string.
-// The return value is the address of a uint  variable that stores the value of the config.
-func Uint(name string, value uint, usage string) *uint {
-	return Configuration.Uint(name, value, usage)
+// writeOptions are the options that control how configs are written.
+type writeOptions struct {
+	align bool            // pad keys so that the '=' signs line up
+	raw   bool            // quote values without escape sequences
+	unset map[string]bool // configs to write commented out
 }
 
-// Uint64Var defines a uint64 config with specified name, default value, and usage string.
-// The argument p points to a uint64 variable in which to store the value of the config.
-func (f *ConfigSet) Uint64Var(p *uint64, name string, value uint64, usage string) {
-	f.Var(newUint64Value(value, p), name, usage)
+// writeOptions returns the options for writing the configs of f. The
+// caller must hold f.mu.
+func (f *ConfigSet) writeOptions() writeOptions {
+	opts := writeOptions{align: f.AlignValues, raw: f.RawQuotes}
+	if f.CommentDefaults {
+		opts.unset = make(map[string]bool)
+		for name := range f.formal {
+			if _, ok := f.actual[name]; !ok {
+				opts.unset[name] = true
+			}
+		}
+	}
+	return opts
 }
 
-// Uint64Var defines a uint64 config with specified name, default value, and usage string.
-// The argument p points to a uint64 variable in which to store the value of the config.
-func Uint64Var(p *uint64, name string, value uint64, usage string) {
-	Configuration.Var(newUint64Value(value, p), name, usage)
+// writeConfig writes config to w in the key=value # usage form or, if
+// width is not 0, with the key padded to width as key = value # usage.
+// The line is commented out if opts.unset holds the config.
+func writeConfig(w io.Writer, config *Config, width int, opts writeOptions) {
+	_, key := splitSection(config.Name)
+	val := formatValue(config.Value.String(), opts.raw)
+	if opts.unset[config.Name] {
+		// A """ block cannot be commented out line by line.
+		if strings.Contains(val, "\n") {
+			val = strconv.Quote(config.Value.String())
+		}
+		fmt.Fprint(w, "# ")
+	}
+	if width > 0 {
+		fmt.Fprintf(w, "%-*s = %s # %s\n", width, key, val, config.Usage)
+		return
+	}
+	fmt.Fprintf(w, "%s=%s # %s\n", key, val, config.Usage)
 }
 
-// Uint64 defines a uint64 config with specified name, default value, and usage string.
-// The return value is the address of a uint64 variable that stores the value of the config.
-func (f *ConfigSet) Uint64(name string, value uint64, usage string) *uint64 {
-	p := new(uint64)
-	f.Uint64Var(p, name, value, usage)
-	return p
+// writeConfigList writes configs to w as opts say.
+func writeConfigList(w io.Writer, configs []*Config, opts writeOptions) {
+	width := keyWidth(configs, opts.align)
+	for _, config := range configs {
+		writeConfig(w, config, width, opts)
+	}
 }
 
-// Uint64 defines a uint64 config with specified name, default value, and usage string.
-// The return value is the address of a uint64 variable that stores the value of the config.
-func Uint64(name string, value uint64, usage string) *uint64 {
-	return Configuration.Uint64(name, value, usage)
+// writeSection writes a [section] header followed by configs to w.
+func writeSection(w io.Writer, section string, configs []*Config, opts writeOptions) {
+	fmt.Fprintf(w, "[%s]\n", section)
+	writeConfigList(w, configs, opts)
 }
 
-// StringVar defines a string config with specified name, default value, and usage string.
-// The argument p points to a string variable in which to store the value of the config.
-func (f *ConfigSet) StringVar(p *string, name string, value string, usage string) {
-	f.Var(newStringValue(value, p), name, usage)
+// writeConfigs writes configs to w, those without a section first and then
+// each section under its own header.
+func writeConfigs(w io.Writer, configs []*Config, opts writeOptions) {
+	sections, grouped := groupSections(configs)
+	writeConfigList(w, grouped[""], opts)
+	for i, section := range sections {
+		if i > 0 || len(grouped[""]) > 0 {
+			fmt.Fprintln(w)
+		}
+		writeSection(w, section, grouped[section], opts)
+	}
 }
 
-// StringVar defines a string config with specified name, default value, and usage string.
-// The argument p points to a string variable in which to store the value of the config.
-func StringVar(p *string, name string, value string, usage string) {
-	Configuration.Var(newStringValue(value, p), name, usage)
+// writeLayout writes layout, as recorded by Load, to w, replacing
+// the values of configs that have changed since. Configs that did not
+// appear in the file are written at the end of their section. If redact
+// is true, the values of secret configs are redacted.
+func (f *ConfigSet) writeLayout(w io.Writer, layout []layoutLine, redact bool) {
+	seen := make(map[string]bool)
+	for _, l := range layout {
+		seen[l.key] = true
+	}
+	configs := make(map[string]*Config)
+	var missing []*Config
+	for _, config := range f.saveConfigs(redact) {
+		configs[config.Name] = config
+		if !seen[config.Name] {
+			missing = append(missing, config)
+		}
+	}
+	sections, grouped := groupSections(missing)
+
+	section := ""
+	for _, l := range layout {
+		if name, ok := parseSection(l.text); ok {
+			writeConfigList(w, grouped[section], f.writeOptions())
+			delete(grouped, section)
+			section = name
+		}
+		config, ok := configs[l.key]
+		if !ok {
+			fmt.Fprintln(w, l.text)
+			continue
+		}
+		val := config.Value.String()
+		if val == l.value {
+			fmt.Fprintln(w, l.text)
+			continue
+		}
+		eq := strings.Index(l.text, "=")
+		rest := l.text[eq+1:]
+		line := l.text[:eq+1] + rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))] + formatValue(val, f.RawQuotes)
+		if ci := commentIndex(l.text, f.commentChars()); ci > -1 && !strings.Contains(l.text, "\n") {
+			space := l.text[len(strings.TrimRight(l.text[:ci], " \t")):ci]
+			if space == "" {
+				space = " "
+			}
+			line += space + l.text[ci:]
+		}
+		fmt.Fprintln(w, line)
+	}
+	writeConfigList(w, grouped[section], f.writeOptions())
+	delete(grouped, section)
+	for _, section := range sections {
+		if configs, ok := grouped[section]; ok {
+			fmt.Fprintln(w)
+			writeSection(w, section, configs, f.writeOptions())
+		}
+	}
 }
 
-// String defines a string config with specified name, default value, and usage string.
-// The return value is the address of a string variable that stores the value of the config.
-func (f *ConfigSet) String(name string, value string, usage string) *string {
-	p := new(string)
-	f.StringVar(p, name, value, usage)
-	return p
+// typeName returns a short name for the type of v for use in usage
+// messages, or "" for a config that needs no argument on the command line.
+func typeName(v Value) string {
+	switch v.(type) {
+	case *boolValue, *countValue:
+		return ""
+	case *intValue, *int64Value, *int32Value:
+		return "int"
+	case *uintValue, *uint64Value, *uint32Value:
+		return "uint"
+	case *stringValue, *enumValue:
+		return "string"
+	case *float64Value, *float32Value:
+		return "float"
+	case *durationValue:
+		return "duration"
+	case *stringSliceValue:
+		return "strings"
+	case *intSliceValue:
+		return "ints"
+	case *durationSliceValue:
+		return "durations"
+	case *stringMapValue:
+		return "map"
+	case *ipValue:
+		return "ip"
+	case *ipNetValue:
+		return "cidr"
+	case *timeValue:
+		return "time"
+	case *bytesValue:
+		return "bytes"
+	case *urlValue:
+		return "url"
+	case *regexpValue:
+		return "regexp"
+	case *levelValue:
+		return "level"
+	case *pathValue:
+		return "path"
+	}
+	if n, ok := v.(interface {
+		typeName() string
+	}); ok {
+		return n.typeName()
+	}
+	if bv, ok := v.(boolConfig); ok && bv.IsBoolConfig() {
+		return ""
+	}
+	return "value"
 }
 
-// String defines a string config with specified name, default value, and usage string.
-// The return value is the address of a string variable that stores the value of the config.
-func String(name string, value string, usage string) *string {
-	return Configuration.String(name, value, usage)
+// UnquoteUsage extracts a back-quoted name from the usage string for a
+// config and returns it and the un-quoted usage. Given "a `name` to show" it
+// returns ("name", "a name to show"). If there are no back quotes, the name
+// is a short name for the type of the config's value.
+func UnquoteUsage(config *Config) (name string, usage string) {
+	return config.UsageName()
 }
 
-// Float64Var defines a float64 config with specified name, default value, and usage string.
-// The argument p points to a float64 variable in which to store the value of the config.
-func (f *ConfigSet) Float64Var(p *float64, name string, value float64, usage string) {
-	f.Var(newFloat64Value(value, p), name, usage)
+// isZeroValue reports whether the default value of config is the zero
+// value of its type, in which case PrintDefaults does not mention it.
+func isZeroValue(config *Config) bool {
+	if config.DefValue == "" {
+		return true
+	}
+	if v := newValue(config.Value); v != nil {
+		return config.DefValue == v.String()
+	}
+	return false
 }
 
-// Float64Var defines a float64 config with specified name, default value, and usage string.
-// The argument p points to a float64 variable in which to store the value of the config.
-func Float64Var(p *float64, name string, value float64, usage string) {
-	Configuration.Var(newFloat64Value(value, p), name, usage)
+// PrintDefaults writes to w a usage message listing each config with the
+// value placeholder given by UsageName, its usage string and, unless it is
+// the zero value, its default value, in the style of the flag package. If
+// HideDeprecated is set, deprecated configs are left out.
+func (f *ConfigSet) PrintDefaults(w io.Writer) {
+	if f.owner != nil {
+		f.owner.printDefaults(w, f.prefix)
+		return
+	}
+	f.printDefaults(w, "")
 }
 
-// Float64 defines a float64 config with specified name, default value, and usage string.
-// The return value is the address of a float64 variable that stores the value of the config.
-func (f *ConfigSet) Float64(name string, value float64, usage string) *float64 {
-	p := new(float64)
-	f.Float64Var(p, name, value, usage)
-	return p
+// printDefaults writes the usage message of PrintDefaults for the configs
+// whose names begin with prefix.
+func (f *ConfigSet) printDefaults(w io.Writer, prefix string) {
+	tw := tabwriter.NewWriter(w, 0, 4, 4, ' ', 0)
+	f.VisitAll(func(config *Config) {
+		if !strings.HasPrefix(config.Name, prefix) {
+			return
+		}
+		if _, ok := f.deprecated[config.Name]; ok && f.HideDeprecated {
+			return
+		}
+		name, usage := config.UsageName()
+		fmt.Fprintf(tw, "  -%s", config.Name)
+		if len(name) > 0 {
+			fmt.Fprintf(tw, " %s", name)
+		}
+		fmt.Fprintf(tw, "\t%s", usage)
+		if !isZeroValue(config) && !f.secrets[config.Name] {
+			if _, ok := config.Value.(*stringValue); ok {
+				fmt.Fprintf(tw, " (default %q)", config.DefValue)
+			} else {
+				fmt.Fprintf(tw, " (default %s)", config.DefValue)
+			}
+		}
+		fmt.Fprintln(tw)
+	})
+	tw.Flush()
 }
 
-// Float64 defines a float64 config with specified name, default value, and usage string.
-// The return value is the address of a float64 variable that stores the value of the config.
-func Float64(name string, value float64, usage string) *float64 {
-	return Configuration.Float64(name, value, usage)
+// PrintDefaults writes to w a usage message listing the command-line configs.
+func PrintDefaults(w io.Writer) {
+	Configuration.PrintDefaults(w)
 }
 
-// DurationVar defines a time.Duration config with specified name, default value, and usage string.
-// The argument p points to a time.Duration variable in which to store the value of the config.
-// The config accepts a value acceptable to time.ParseDuration.
-func (f *ConfigSet) DurationVar(p *time.Duration, name string, value time.Duration, usage string) {
-	f.Var(newDurationValue(value, p), name, usage)
+// Print will dump all the current configuration settings to standard output.
+// If HideDeprecated is set, deprecated configs are left out. The values of
+// configs marked with SetSecret are redacted.
+func (f *ConfigSet) Print() {
+	f.dump(os.Stdout, "")
 }
 
-// DurationVar defines a time.Duration config with specified name, default value, and usage string.
-// The argument p points to a time.Duration variable in which to store the value of the config.
-// The config accepts a value acceptable to time.ParseDuration.
-func DurationVar(p *time.Duration, name string, value time.Duration, usage string) {
-	Configuration.Var(newDurationValue(value, p), name, usage)
+// Dump returns the listing of the current configuration settings that
+// Print writes. As with Print, the values of secret configs are redacted.
+func (f *ConfigSet) Dump() string {
+	var b strings.Builder
+	f.dump(&b, "")
+	return b.String()
 }
 
-// Duration defines a time.Duration config with specified name, default value, and usage string.
-// The return value is the address of a time.Duration variable that stores the value of the config.
-// The config accepts a value acceptable to time.ParseDuration.
-func (f *ConfigSet) Duration(name string, value time.Duration, usage string) *time.Duration {
-	p := new(time.Duration)
-	f.DurationVar(p, name, value, usage)
-	return p
+// DumpTagged returns the listing that Dump returns, of only the configs
+// that have the tag.
+func (f *ConfigSet) DumpTagged(tag string) string {
+	var b strings.Builder
+	f.dump(&b, tag)
+	return b.String()
 }
 
-// Duration defines a time.Duration config with specified name, default value, and usage string.
-// The return value is the address of a time.Duration variable that stores the value of the config.
-// The config accepts a value acceptable to time.ParseDuration.
-func Duration(name string, value time.Duration, usage string) *time.Duration {
-	return Configuration.Duration(name, value, usage)
+// dump writes the listing of Print and Dump to w. If tag is not empty, only
+// the configs that have it are listed; for a section, only its configs are.
+func (f *ConfigSet) dump(w io.Writer, tag string) {
+	if f.owner != nil {
+		f.owner.dumpConfigs(w, tag, f.prefix)
+		return
+	}
+	f.dumpConfigs(w, tag, "")
 }
 
-// Var defines a config with the specified name and usage string. The type and
-// value of the config are represented by the first argument, of type Value, which
-// typically holds a user-defined implementation of Value. For instance, the
-// caller could create a config that turns a comma-separated string into a slice
-// of strings by giving the slice the methods of Value; in particular, Set would
-// decompose the comma-separated string into the slice.
-func (f *ConfigSet) Var(value Value, name string, usage string) {
-	// Remember the default value as a string; it won't change.
-	config := &Config{name, usage, value, value.String()}
-	_, alreadythere := f.formal[name]
-	if alreadythere {
-		var msg string
-		if f.filename == "" {
-			msg = fmt.Sprintf("config redefined: %s", name)
-		} else {
-			msg = fmt.Sprintf("%s config redefined: %s", f.filename, name)
+// dumpConfigs writes the listing of dump for the configs whose names begin
+// with prefix.
+func (f *ConfigSet) dumpConfigs(w io.Writer, tag, prefix string) {
+	visitor := func(config *Config) {
+		if !strings.HasPrefix(config.Name, prefix) {
+			return
 		}
-		fmt.Println(msg)
-		panic(msg) // Happens only if configs are declared with identical names
+		if _, ok := f.deprecated[config.Name]; ok && f.HideDeprecated {
+			return
+		}
+		if tag != "" && !f.hasTag(config.Name, tag) {
+			return
+		}
+		config = f.redacted(config)
+		fmt.Fprintf(w, "%-20s = %s # %s\n", config.Name, config.Value.String(), config.Usage)
 	}
-	if f.formal == nil {
-		f.formal = make(map[string]*Config)
+	f.VisitAll(visitor)
+}
+
+// commentChars returns the characters that begin a comment in a config file.
+func (f *ConfigSet) commentChars() string {
+	if f.CommentChars == "" {
+		return "#;"
 	}
-	f.formal[name] = config
+	return f.CommentChars
 }
 
-// Var defines a config with the specified name and usage string. The type and
-// value of the config are represented by the first argument, of type Value, which
-// typically holds a user-defined implementation of Value. For instance, the
-// caller could create a config that turns a comma-separated string into a slice
-// of strings by giving the slice the methods of Value; in particular, Set would
-// decompose the comma-separated string into the slice.
-func Var(value Value, name string, usage string) {
-	Configuration.Var(value, name, usage)
+// commentIndex returns the index of the comment character, one of chars,
+// beginning the trailing comment of a config file line, or -1 if there is
+// none. A comment character begins a comment only when it is outside
+// double quotes, is not escaped with a backslash and is either at the
+// start of the line or preceded by whitespace. Inside double quotes a
+// backslash escapes the next character, as in a Go string.
+func commentIndex(line, chars string) int {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line) && (quoted || strings.IndexByte(chars, line[i+1]) > -1):
+			i++
+		case c == '"':
+			quoted = !quoted
+		case strings.IndexByte(chars, c) > -1 && !quoted && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return i
+		}
+	}
+	return -1
 }
 
-// Configuration is the default set of command-line configs, parsed from os.Args.
-// The top-level functions such as BoolVar, Arg, and so on are wrappers for the
-// methods of Configuration.
-var Configuration = NewConfigSet("")
+// stripComment removes the trailing comment from a config file line and
+// turns each comment character escaped with a backslash outside double
+// quotes, such as "\#", into a literal one.
+func stripComment(line, chars string) string {
+	if ci := commentIndex(line, chars); ci > -1 {
+		line = line[:ci]
+	}
+	if !strings.Contains(line, `\`) {
+		return line
+	}
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line) && quoted:
+			b.WriteByte(c)
+			i++
+			c = line[i]
+		case c == '\\' && i+1 < len(line) && strings.IndexByte(chars, line[i+1]) > -1:
+			i++
+			c = line[i]
+		case c == '"':
+			quoted = !quoted
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
 
-func init() {
-	//Configuration.filename = ""
+// Load reads the configuration from the filename configured in the
+// NewConfigSet function. A value may be written as a Go quoted string,
+// continued onto the next line with a trailing backslash, or spread over
+// several lines between """ delimiters. A list or map config named on
+// several lines holds the values from all of them, replacing its earlier
+// value; any other config takes the value on the last line. Lines that
+// fail to parse are handled according to the error handling policy; under
+// ContinueOnError every bad line is reported in the returned error.
+func (f *ConfigSet) Load() error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	if f.filename == "" {
+		return errors.New("no file to load")
+	}
+	f.logf("loading config from %s", f.filename)
+	in, err := os.Open(f.filename)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	defer in.Close()
+	return f.load(in, f.filename, nil, false)
 }
 
-// NewConfigSet returns a new, empty config set with the specified name and
-// error handling property.
-func NewConfigSet(filename string) *ConfigSet {
-	f := &ConfigSet{
-		filename: filename,
+// LoadFiles reads the configuration from each of the named files in turn,
+// so that a value in a later file overrides one in an earlier file. Files
+// that do not exist are skipped unless RequireFiles is set. Errors from
+// every file are reported in the returned error; under ContinueOnError the
+// remaining files are still loaded.
+func (f *ConfigSet) LoadFiles(filenames ...string) error {
+	if f.owner != nil {
+		return f.errSection()
 	}
-	//f.Usage = f.defaultUsage
-	return f
+	var errs []error
+	for _, filename := range filenames {
+		in, err := os.Open(filename)
+		if os.IsNotExist(err) && !f.RequireFiles {
+			f.logf("skipping missing config file %s", filename)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("loading config: %w", err))
+			continue
+		}
+		f.logf("loading config from %s", filename)
+		err = f.load(in, filename, nil, false)
+		in.Close()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
-// Init sets the name and error handling property for a config set.
-// By default, the zero ConfigSet uses an empty name and the
-// ContinueOnError error handling policy.
-func (f *ConfigSet) Init(filename string) {
-	f.filename = filename
+// LoadFirstFound reads the configuration from the first of the named files
+// that exists, such as a file in the working directory followed by ones in
+// the user's and the system's configuration directories, and returns its
+// name. Files that do not exist are skipped; a file that exists but cannot
+// be read is an error. If none of the files exists, LoadFirstFound returns
+// "" and no error.
+func (f *ConfigSet) LoadFirstFound(filenames ...string) (string, error) {
+	if f.owner != nil {
+		return "", f.errSection()
+	}
+	for _, filename := range filenames {
+		in, err := os.Open(filename)
+		if os.IsNotExist(err) {
+			f.logf("skipping missing config file %s", filename)
+			continue
+		}
+		if err != nil {
+			return filename, fmt.Errorf("loading config: %w", err)
+		}
+		defer in.Close()
+		f.logf("loading config from %s", filename)
+		return filename, f.load(in, filename, nil, false)
+	}
+	return "", nil
 }
 
-// Save writes the configuration to the filename configured in the
-// NewConfigSet function
-func (f *ConfigSet) Save() {
-	if f.filename == "" {
-		fmt.Printf("No filename to save.\n")
-		return
+// LoadedFrom returns the name of the file the value of the named config
+// was loaded from by Load or LoadFiles, or "" if its value did not come
+// from a file.
+func (f *ConfigSet) LoadedFrom(name string) string {
+	source := f.Source(name)
+	if !strings.HasPrefix(source, "file:") {
+		return ""
 	}
-	fmt.Printf("Writing config to %s\n", f.filename)
-	out, err := os.Create(f.filename)
-	if err != nil {
-		return
+	return source[len("file:"):]
+}
+
+// LoadFrom reads the configuration from r in the format read by Load.
+func (f *ConfigSet) LoadFrom(r io.Reader) error {
+	if f.owner != nil {
+		return f.errSection()
 	}
-	defer out.Close()
+	return f.load(r, "", nil, false)
+}
 
-	visitor := func(f *Config) {
-		fmt.Fprintf(out, "%s=%s # %s\n", f.Name, f.Value.String(), f.Usage)
+// LoadBytes reads the configuration from b in the format read by Load.
+func (f *ConfigSet) LoadBytes(b []byte) error {
+	if f.owner != nil {
+		return f.errSection()
 	}
-	VisitAll(visitor)
-	fmt.Printf("Done.\n")
+	return f.load(bytes.NewReader(b), "", nil, false)
 }
 
-// Print will dump all the current configuration settings
-func (f *ConfigSet) Print() {
-	visitor := func(f *Config) {
-		fmt.Printf("%-20s = %s # %s\n", f.Name, f.Value.String(), f.Usage)
+// LoadAllowed reads the configuration from r in the format read by Load,
+// but sets only the configs named in allowed, so that the file cannot
+// change any other config. Lines setting other configs are skipped, or
+// handled as errors if RejectDisallowed is set.
+func (f *ConfigSet) LoadAllowed(r io.Reader, allowed []string) error {
+	if f.owner != nil {
+		return f.errSection()
 	}
-	VisitAll(visitor)
+	f.mu.Lock()
+	names := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		names[f.canonical(name)] = true
+	}
+	f.unlock()
+	return f.load(r, "", names, false)
 }
 
-func (f *ConfigSet) Load() {
-	if f.filename == "" {
-		fmt.Printf("No file to load.\n")
-		return
+// expandEnv replaces ${VAR} and $VAR in s with the value of the environment
+// variable VAR, and $$ with a literal $. Variables that are not set expand to
+// the empty string, or are reported as an error if strict is true.
+func expandEnv(s string, strict bool) (string, error) {
+	var missing []string
+	s = os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		v, ok := os.LookupEnv(name)
+		if !ok && strict {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return s, fmt.Errorf("environment variable not set: %s", strings.Join(missing, ", "))
+	}
+	return s, nil
+}
+
+// load reads the configuration from r. The source names r in error
+// messages and may be empty. If allowed is not nil, only the configs it
+// holds are set. If collect is true, every error is returned rather than
+// handled according to the error handling policy.
+func (f *ConfigSet) load(r io.Reader, source string, allowed map[string]bool, collect bool) error {
+	f.mu.Lock()
+	defer f.unlock()
+	if f.frozen {
+		if collect {
+			return ErrFrozen
+		}
+		return f.errFrozen()
+	}
+	if f.PreserveLayout {
+		f.layout = []layoutLine{}
+	}
+	var lines []fileLine
+	err := scanLines(r, f.commentChars(), f.RawQuotes, func(l fileLine) {
+		lines = append(lines, l)
+	})
+	var fileValues map[string]string
+	if f.ExpandConfigs {
+		fileValues = make(map[string]string)
+		for _, l := range lines {
+			if l.kv && l.err == nil {
+				fileValues[f.canonical(l.key)] = l.value
+			}
+		}
+	}
+	var errs []error
+	seen := make(map[string]bool)
+	for _, l := range lines {
+		if !l.kv {
+			if f.PreserveLayout {
+				f.layout = append(f.layout, layoutLine{text: l.text})
+			}
+			continue
+		}
+		key := f.canonical(l.key)
+		val := l.value
+		err := l.err
+		if allowed != nil && !allowed[key] {
+			if !f.RejectDisallowed {
+				if f.PreserveLayout {
+					f.layout = append(f.layout, layoutLine{text: l.text})
+				}
+				continue
+			}
+			err = fmt.Errorf("config %s may not be set by this file", key)
+		}
+		if err == nil && f.ExpandConfigs {
+			val, err = f.expandRefs(val, fileValues, []string{key})
+		}
+		if err == nil && f.ExpandEnv {
+			val, err = expandEnv(val, f.StrictEnv)
+		}
+		if err == nil {
+			from := "reader"
+			if source != "" {
+				from = "file:" + source
+			}
+			mode := setNormal
+			if config, ok := f.formal[key]; ok {
+				if _, ok := config.Value.(Appender); ok {
+					mode = setReplace
+					if seen[key] {
+						mode = setAppend
+					}
+				} else if seen[key] {
+					f.logf("config %s is set more than once in %s; using the last value", key, from)
+				}
+			}
+			seen[key] = true
+			if err = f.set(key, val, mode); err == nil {
+				f.setSource(key, from)
+			} else if f.formal[key] != nil {
+				err = fmt.Errorf("invalid value %q for config -%s: %w", val, key, err)
+			}
+		}
+		if f.PreserveLayout {
+			if config, ok := f.formal[key]; ok && err == nil {
+				val = config.Value.String()
+			}
+			f.layout = append(f.layout, layoutLine{l.text, key, val})
+		}
+		if err != nil {
+			if source != "" {
+				err = f.failf("%s:%d: %s: %w", source, l.lineno, firstLine(l.text), err)
+			} else {
+				err = f.failf("line %d: %s: %w", l.lineno, firstLine(l.text), err)
+			}
+			if !collect {
+				err = f.handleError(err)
+			}
+			errs = append(errs, err)
+		}
 	}
-	fmt.Printf("Loading config from %s\n", f.filename)
-	in, err := os.Open(f.filename)
 	if err != nil {
-		return
+		return fmt.Errorf("loading config: %w", err)
 	}
-	defer in.Close()
+	return errors.Join(errs...)
+}
 
-	scanner := bufio.NewScanner(in)
-	for scanner.Scan() {
-		line := scanner.Text()
-		//fmt.Printf("LINE: [%s]\n", line)
-		ci := strings.Index(line, "#")
-		if ci > -1 {
-			line = line[:ci]
-		}
-		kv := strings.Split(line, "=")
-		if len(kv) == 2 {
-			key := strings.TrimSpace(kv[0])
-			val := strings.Trim(strings.TrimSpace(kv[1]), `"`)
-			err = f.Set(key, val)
+// expandRefs replaces each ${name} in val that names a config with the
+// config's value: its value in the file being loaded, itself expanded, if
+// fileValues has one, and its current value otherwise. path lists the
+// configs whose values are being expanded, to detect cycles. References to
+// other names are left for expandEnv if ExpandEnv is set, and are an error
+// otherwise, as is a cycle. The caller must hold f.mu.
+func (f *ConfigSet) expandRefs(val string, fileValues map[string]string, path []string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+		if c != '$' || i+1 == len(val) {
+			b.WriteByte(c)
+			continue
+		}
+		switch val[i+1] {
+		case '$':
+			// Keep $$ for expandEnv, which turns it into $.
+			if f.ExpandEnv {
+				b.WriteString("$$")
+			} else {
+				b.WriteByte('$')
+			}
+			i++
+			continue
+		case '{':
+		default:
+			b.WriteByte(c)
+			continue
+		}
+		end := strings.IndexByte(val[i+2:], '}')
+		if end < 0 {
+			b.WriteString(val[i:])
+			break
+		}
+		ref := val[i+2 : i+2+end]
+		name := f.canonical(ref)
+		for j, p := range path {
+			if p == name {
+				return "", fmt.Errorf("config reference cycle: %s", strings.Join(append(path[j:], name), " -> "))
+			}
+		}
+		if raw, ok := fileValues[name]; ok {
+			expanded, err := f.expandRefs(raw, fileValues, append(path, name))
 			if err != nil {
-				fmt.Printf("f.Set returned err=%v\n", err)
+				return "", err
 			}
+			b.WriteString(expanded)
+		} else if config, ok := f.formal[name]; ok {
+			b.WriteString(config.Value.String())
+		} else if f.ExpandEnv {
+			b.WriteString(val[i : i+3+end])
+		} else {
+			return "", fmt.Errorf("reference to undefined config %s", ref)
 		}
+		i += 2 + end
 	}
+	return b.String(), nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return
+// A fileLine is a logical line of a config file, which spans several lines
+// of text when its value is continued or is a """ block.
+type fileLine struct {
+	text   string // lines as read, joined by newlines
+	lineno int    // number of the first line
+	kv     bool   // whether the line is a key = value line
+	key    string // config name, including the section
+	value  string // value, unquoted
+	err    error  // error reading the value
+}
+
+// scanLines reads a config file from r, calling fn for each logical line.
+// chars are the comment characters, and raw says whether quoted values are
+// taken literally, as for RawQuotes. Lines may end in "\r\n", which the
+// scanner drops like "\n", and a UTF-8 byte order mark at the start of the
+// file is skipped. It returns any error reading r.
+func scanLines(r io.Reader, chars string, raw bool, fn func(fileLine)) error {
+	section := ""
+	lineno := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineno++
+		l := fileLine{text: scanner.Text(), lineno: lineno}
+		if lineno == 1 {
+			l.text = strings.TrimPrefix(l.text, "\ufeff")
+		}
+		line := stripComment(l.text, chars)
+		isBlock := false
+		var block string
+		if i := blockStart(l.text, chars); i > -1 {
+			l.text, block, l.err = readBlock(scanner, l.text, i, &lineno)
+			line = l.text[:i]
+			isBlock = true
+		} else {
+			// A trailing backslash continues the value on the next line.
+			for strings.HasSuffix(line, `\`) && scanner.Scan() {
+				lineno++
+				next := scanner.Text()
+				l.text += "\n" + next
+				line = line[:len(line)-1] + strings.TrimLeft(stripComment(next, chars), " \t")
+			}
+		}
+		if name, ok := parseSection(line); ok {
+			section = name
+		}
+		if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+			l.kv = true
+			l.key = strings.TrimSpace(kv[0])
+			if section != "" {
+				l.key = section + "." + l.key
+			}
+			l.value = parseValue(kv[1], raw)
+			if isBlock {
+				l.value = block
+			}
+		}
+		fn(l)
 	}
+	return scanner.Err()
 }
 
 func SetFile(filename string) {
 	Configuration.filename = filename
 }
 
-func Save() {
-	Configuration.Save()
+func Save() error {
+	return Configuration.Save()
 }
 
 func Print() {
 	Configuration.Print()
 }
 
-func Load() {
-	Configuration.Load()
+// Dump returns the listing of the command-line configs that Print writes.
+func Dump() string {
+	return Configuration.Dump()
+}
+
+// DumpTagged returns the listing of the command-line configs that have the
+// tag.
+func DumpTagged(tag string) string {
+	return Configuration.DumpTagged(tag)
+}
+
+func Load() error {
+	return Configuration.Load()
+}
+
+// LoadFiles reads the command-line configs from each of the named files
+// in turn.
+func LoadFiles(filenames ...string) error {
+	return Configuration.LoadFiles(filenames...)
+}
+
+// LoadFirstFound reads the command-line configs from the first of the
+// named files that exists and returns its name.
+func LoadFirstFound(filenames ...string) (string, error) {
+	return Configuration.LoadFirstFound(filenames...)
+}
+
+// LoadedFrom returns the name of the file the named command-line config
+// was last loaded from.
+func LoadedFrom(name string) string {
+	return Configuration.LoadedFrom(name)
+}
+
+// SetLogger sets the destination for diagnostic messages of the
+// command-line config set.
+func SetLogger(logger io.Writer) {
+	Configuration.SetLogger(logger)
 }
@@ -0,0 +1,3262 @@
+package goflagconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSet returns a ContinueOnError config set that discards its output.
+func newTestSet() *ConfigSet {
+	f := NewConfigSet("", ContinueOnError)
+	f.SetOutput(io.Discard)
+	return f
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		args []string
+		b    bool
+		s    string
+		n    int
+		rest []string
+	}{
+		{[]string{}, false, "", 0, []string{}},
+		{[]string{"-b"}, true, "", 0, []string{}},
+		{[]string{"--b"}, true, "", 0, []string{}},
+		{[]string{"-b=false"}, false, "", 0, []string{}},
+		{[]string{"-s", "x"}, false, "x", 0, []string{}},
+		{[]string{"--s=x"}, false, "x", 0, []string{}},
+		{[]string{"-s=a=b"}, false, "a=b", 0, []string{}},
+		{[]string{"-n", "7", "arg", "-b"}, false, "", 7, []string{"arg", "-b"}},
+		{[]string{"-n=7", "--", "-b"}, false, "", 7, []string{"-b"}},
+		{[]string{"-b", "value"}, true, "", 0, []string{"value"}},
+		{[]string{"-", "-b"}, false, "", 0, []string{"-", "-b"}},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		b := f.Bool("b", false, "")
+		s := f.String("s", "", "")
+		n := f.Int("n", 0, "")
+		if err := f.Parse(tt.args); err != nil {
+			t.Errorf("Parse(%q): %v", tt.args, err)
+			continue
+		}
+		if *b != tt.b || *s != tt.s || *n != tt.n {
+			t.Errorf("Parse(%q): b=%v s=%q n=%d, want b=%v s=%q n=%d", tt.args, *b, *s, *n, tt.b, tt.s, tt.n)
+		}
+		if rest := f.Args(); !reflect.DeepEqual(rest, tt.rest) {
+			t.Errorf("Parse(%q): Args() = %q, want %q", tt.args, rest, tt.rest)
+		}
+		if !f.Parsed() {
+			t.Errorf("Parse(%q): Parsed() = false", tt.args)
+		}
+	}
+}
+
+func TestParseRecordsActual(t *testing.T) {
+	f := newTestSet()
+	f.Int("n", 0, "")
+	f.Int("m", 0, "")
+	if err := f.Parse([]string{"-n", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Changed("n") || f.Changed("m") {
+		t.Errorf("Changed(n), Changed(m) = %v, %v; want true, false", f.Changed("n"), f.Changed("m"))
+	}
+	if got := f.NConfig(); got != 1 {
+		t.Errorf("NConfig() = %d, want 1", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := [][]string{
+		{"-n", "x"},
+		{"-n"},
+		{"---n=1"},
+		{"-=1"},
+	}
+	for _, args := range tests {
+		f := newTestSet()
+		f.Int("n", 0, "")
+		if err := f.Parse(args); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", args)
+		}
+	}
+}
+
+// countingBool is a boolean-like Value counting how often it is set.
+type countingBool int
+
+func (c *countingBool) Set(s string) error {
+	if s != "true" {
+		return fmt.Errorf("unexpected value %q", s)
+	}
+	*c++
+	return nil
+}
+
+func (c *countingBool) Get() interface{}   { return int(*c) }
+func (c *countingBool) String() string     { return strconv.Itoa(int(*c)) }
+func (c *countingBool) IsBoolConfig() bool { return true }
+
+func TestIsBoolConfig(t *testing.T) {
+	f := newTestSet()
+	var c countingBool
+	f.Var(&c, "c", "")
+	if err := f.Parse([]string{"-c", "-c", "arg"}); err != nil {
+		t.Fatal(err)
+	}
+	if c != 2 {
+		t.Errorf("c = %d, want 2", c)
+	}
+	if rest := f.Args(); !reflect.DeepEqual(rest, []string{"arg"}) {
+		t.Errorf("Args() = %q, want [arg]", rest)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	f := newTestSet()
+	f.Int("n", 0, "")
+	if err := f.Parse([]string{"-n=x"}); err == nil {
+		t.Error("Parse under ContinueOnError returned no error")
+	}
+	if err := f.LoadBytes([]byte("n=x\n")); err == nil {
+		t.Error("LoadBytes under ContinueOnError returned no error")
+	}
+
+	p := NewConfigSet("", PanicOnError)
+	p.SetOutput(io.Discard)
+	p.Int("n", 0, "")
+	for _, load := range []func() error{
+		func() error { return p.Parse([]string{"-n=x"}) },
+		func() error { return p.LoadBytes([]byte("n=x\n")) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic under PanicOnError")
+				}
+			}()
+			load()
+		}()
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	f := NewConfigSet(filepath.Join(dir, "missing.conf"), ContinueOnError)
+	f.SetOutput(io.Discard)
+	if err := f.Load(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load of a missing file: error = %v, want ErrNotExist", err)
+	}
+
+	name := filepath.Join(dir, "bad.conf")
+	if err := os.WriteFile(name, []byte("n=1\nn=x\nm=2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f = NewConfigSet(name, ContinueOnError)
+	var out bytes.Buffer
+	f.SetOutput(&out)
+	n := f.Int("n", 0, "")
+	err := f.Load()
+	if err == nil {
+		t.Fatal("Load of a bad file succeeded")
+	}
+	for _, want := range []string{"bad.conf:2: n=x", "bad.conf:3: m=2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load error %q does not mention %q", err, want)
+		}
+	}
+	if !strings.Contains(out.String(), "bad.conf:2") {
+		t.Errorf("errors not written to the output: %q", out.String())
+	}
+	if *n != 1 {
+		t.Errorf("n = %d, want 1", *n)
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.conf")
+	if err := os.WriteFile(name, []byte("old contents\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := NewConfigSet(name, ContinueOnError)
+	f.Int("n", 3, "a number")
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "n=3 # a number\n"; string(data) != want {
+		t.Errorf("saved %q, want %q", data, want)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Save left %d files in the directory, want 1", len(entries))
+	}
+
+	if err := NewConfigSet(filepath.Join(dir, "no", "such", "dir.conf"), ContinueOnError).Save(); err == nil {
+		t.Error("Save into a missing directory succeeded")
+	}
+	if err := NewConfigSet("", ContinueOnError).Save(); err == nil {
+		t.Error("Save without a filename succeeded")
+	}
+}
+
+// roundTrip saves f and loads the result into g, failing t on any error.
+func roundTrip(t *testing.T, f, g *ConfigSet) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := f.SaveTo(&buf); err != nil {
+		t.Fatalf("SaveTo: %v", err)
+	}
+	if err := g.LoadFrom(&buf); err != nil {
+		t.Fatalf("LoadFrom: %v\n%s", err, buf.String())
+	}
+}
+
+func TestLoadValueWithEquals(t *testing.T) {
+	values := []string{
+		"abc==",
+		"http://h/?a=1&b=2",
+		"user=x;pw=y",
+		"=",
+	}
+	for _, v := range values {
+		f := newTestSet()
+		s := f.String("s", "", "")
+		if err := f.LoadBytes([]byte("s=" + v + "\n")); err != nil {
+			t.Errorf("LoadBytes(s=%s): %v", v, err)
+		} else if *s != v {
+			t.Errorf("LoadBytes(s=%s): s = %q", v, *s)
+		}
+
+		g := newTestSet()
+		gs := g.String("s", "", "")
+		*s = v
+		roundTrip(t, f, g)
+		if *gs != v {
+			t.Errorf("round trip of %q gave %q", v, *gs)
+		}
+	}
+}
+
+func TestLoadComments(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`s=plain # comment`, "plain"},
+		{`s="#ff0000"`, "#ff0000"},
+		{`s="#ff0000" # red`, "#ff0000"},
+		{`s="a # b" # c`, "a # b"},
+		{`s=a\#b`, "a#b"},
+		{`s=a\#b # comment`, "a#b"},
+		{`s=a#b`, "a#b"},
+		{`# s=ignored`, "default"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		s := f.String("s", "default", "")
+		if err := f.LoadBytes([]byte(tt.line + "\n")); err != nil {
+			t.Errorf("LoadBytes(%s): %v", tt.line, err)
+			continue
+		}
+		if *s != tt.want {
+			t.Errorf("LoadBytes(%s): s = %q, want %q", tt.line, *s, tt.want)
+		}
+	}
+
+	for _, v := range []string{"#ff0000", "a#b", "a # b", `\#`} {
+		f, g := newTestSet(), newTestSet()
+		f.String("s", v, "usage # with hash")
+		gs := g.String("s", "", "")
+		roundTrip(t, f, g)
+		if *gs != v {
+			t.Errorf("round trip of %q gave %q", v, *gs)
+		}
+	}
+}
+
+func TestPreserveLayout(t *testing.T) {
+	f := newTestSet()
+	f.PreserveLayout = true
+	f.Int("b", 1, "bee")
+	f.Int("a", 1, "ay")
+	f.Int("c", 5, "see")
+	in := "# header\n\nb = 2   # my note\n# between\na=3\n"
+	if err := f.LoadBytes([]byte(in)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := f.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := in + "c=5 # see\n"; buf.String() != want {
+		t.Errorf("unchanged save:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	if err := f.Set("b", "9"); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := f.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "# header\n\nb = 9   # my note\n# between\na=3\nc=5 # see\n"
+	if buf.String() != want {
+		t.Errorf("save after Set:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestSections(t *testing.T) {
+	f := newTestSet()
+	name := f.String("name", "app", "")
+	db := f.Section("database")
+	host := db.String("host", "localhost", "")
+	dbPort := db.Int("port", 5432, "")
+	srvPort := f.Section("server").Int("port", 80, "")
+
+	in := "name=svc\n\n[database]\nhost=db.example.com\nport = 6543\n\n[server]\nport=8080\n"
+	if err := f.LoadBytes([]byte(in)); err != nil {
+		t.Fatal(err)
+	}
+	if *name != "svc" || *host != "db.example.com" || *dbPort != 6543 || *srvPort != 8080 {
+		t.Errorf("name=%q host=%q database.port=%d server.port=%d", *name, *host, *dbPort, *srvPort)
+	}
+	if f.Lookup("database.port") == nil || db.Lookup("port") == nil {
+		t.Error("database.port is not defined under its full and section names")
+	}
+	if err := db.Set("port", "1"); err != nil || *dbPort != 1 {
+		t.Errorf("db.Set(port): port = %d, %v", *dbPort, err)
+	}
+
+	var buf bytes.Buffer
+	if err := f.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"name=svc", "[database]\nhost=db.example.com", "[server]\nport=8080"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("SaveTo output does not contain %q:\n%s", want, out)
+		}
+	}
+	if i, j := strings.Index(out, "name="), strings.Index(out, "["); i > j {
+		t.Errorf("configs without a section are not first:\n%s", out)
+	}
+
+	g := newTestSet()
+	g.String("name", "", "")
+	gdb := g.Section("database")
+	gdb.String("host", "", "")
+	gdb.Int("port", 0, "")
+	g.Section("server").Int("port", 0, "")
+	roundTrip(t, f, g)
+	if diffs := f.Diff(g); len(diffs) != 0 {
+		t.Errorf("round trip differs: %v", diffs)
+	}
+}
+
+func TestSectionMethods(t *testing.T) {
+	f := newTestSet()
+	f.Int("top", 1, "")
+	db := f.Section("db")
+	port := db.Int("port", 5432, "")
+	db.String("host", "localhost", "")
+	db.Section("pool").Int("size", 4, "")
+	f.Section("dbx").Int("n", 0, "")
+	f.Set("top", "2")
+	db.Set("port", "0")
+	f.Parse([]string{"arg"})
+	// The validators are registered after the values they reject are set.
+	db.SetValidator("port", func(v Value) error {
+		if v.(Getter).Get().(int) == 0 {
+			return errors.New("port is zero")
+		}
+		return nil
+	})
+	f.SetValidator("top", func(Value) error { return errors.New("top is bad") })
+
+	names := func(visit func(func(*Config))) []string {
+		var names []string
+		visit(func(config *Config) { names = append(names, config.Name) })
+		return names
+	}
+	all := []string{"db.host", "db.pool.size", "db.port"}
+	visits := []struct {
+		name  string
+		visit func(func(*Config))
+		want  []string
+	}{
+		{"VisitAll", db.VisitAll, all},
+		{"VisitAllOrdered", db.VisitAllOrdered, []string{"db.port", "db.host", "db.pool.size"}},
+		{"Visit", db.Visit, []string{"db.port"}},
+		{"VisitUnset", db.VisitUnset, []string{"db.host", "db.pool.size"}},
+		{"Walk", func(fn func(*Config)) {
+			db.Walk(func(config *Config) error { fn(config); return nil })
+		}, all},
+		{"pool.VisitAll", db.Section("pool").VisitAll, []string{"db.pool.size"}},
+	}
+	for _, tt := range visits {
+		if got := names(tt.visit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s visited %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if n := db.NConfig(); n != 1 {
+		t.Errorf("NConfig() = %d, want 1", n)
+	}
+	if got, want := db.GetAll(), map[string]string{"port": "0", "host": "localhost", "pool.size": "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAll() = %v, want %v", got, want)
+	}
+	if errs := db.Validate(); len(errs) != 1 || errs[0].Error() != "config db.port: port is zero" {
+		t.Errorf("Validate() = %v, want the error for db.port only", errs)
+	}
+	if dump := db.Dump(); strings.Contains(dump, "top") || !strings.Contains(dump, "db.port") {
+		t.Errorf("Dump() =\n%s\nwant the configs of db only", dump)
+	}
+	var usage bytes.Buffer
+	db.PrintDefaults(&usage)
+	if out := usage.String(); strings.Contains(out, "top") || strings.Contains(out, "dbx") || !strings.Contains(out, "-db.host") {
+		t.Errorf("PrintDefaults wrote\n%s\nwant the configs of db only", out)
+	}
+	if !db.Parsed() || !reflect.DeepEqual(db.Args(), []string{"arg"}) || db.NArg() != 1 || db.Arg(0) != "arg" {
+		t.Errorf("Parsed, Args = %v, %q; want those of f", db.Parsed(), db.Args())
+	}
+	if db.Output() != f.Output() {
+		t.Error("Output() is not that of f")
+	}
+
+	db.Reset()
+	if db.Changed("port") || !f.Changed("top") {
+		t.Errorf("after db.Reset: Changed(db.port), Changed(top) = %v, %v; want false, true", f.Changed("db.port"), f.Changed("top"))
+	}
+	db.Set("port", "1")
+	if err := db.ResetToDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if *port != 5432 || db.Changed("port") {
+		t.Errorf("after db.ResetToDefaults: port = %d, Changed = %v", *port, db.Changed("port"))
+	}
+	if v, _ := f.GetInt("top"); v != 2 || !f.Changed("top") {
+		t.Errorf("db.ResetToDefaults changed top to %d", v)
+	}
+
+	if err := db.ApplySnapshot(db.Snapshot()); err != nil {
+		t.Errorf("ApplySnapshot(Snapshot()): %v", err)
+	}
+
+	// Methods that act on a whole set are refused.
+	for name, fn := range map[string]func() error{
+		"Parse":    func() error { return db.Parse(nil) },
+		"Load":     db.Load,
+		"Save":     db.Save,
+		"LoadFrom": func() error { return db.LoadFrom(strings.NewReader("port=1\n")) },
+		"SaveTo":   func() error { return db.SaveTo(io.Discard) },
+		"SaveJSON": func() error { return db.SaveJSON(io.Discard) },
+		"LoadTOML": func() error { return db.LoadTOML(strings.NewReader("")) },
+		"Merge":    func() error { return db.Merge(newTestSet(), true) },
+		"Resolve":  db.Resolve,
+		"LoadFirstFound": func() error {
+			_, err := db.LoadFirstFound("missing.conf")
+			return err
+		},
+	} {
+		if err := fn(); err != ErrSection {
+			t.Errorf("%s on a section = %v, want ErrSection", name, err)
+		}
+	}
+	for name, fn := range map[string]func(){
+		"Init":       func() { db.Init("x.conf", ContinueOnError) },
+		"Clone":      func() { db.Clone() },
+		"Subcommand": func() { db.Subcommand("sub") },
+		"SetOutput":  func() { db.SetOutput(io.Discard) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s on a section did not panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestLoadFromSaveTo(t *testing.T) {
+	f := newTestSet()
+	f.Int("n", 1, "num")
+	f.String("s", "x", "str")
+	if err := f.LoadFrom(strings.NewReader("n=2\ns=y\n")); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := f.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "n=2 # num\ns=y # str\n"; buf.String() != want {
+		t.Errorf("SaveTo wrote %q, want %q", buf.String(), want)
+	}
+	if err := f.SaveTo(errWriter{}); err == nil {
+		t.Error("SaveTo ignored a write error")
+	}
+	if err := f.LoadFrom(errReader{}); err == nil {
+		t.Error("LoadFrom ignored a read error")
+	}
+}
+
+// errWriter is an io.Writer that always fails.
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+// errReader is an io.Reader that always fails.
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func TestGetters(t *testing.T) {
+	f := newTestSet()
+	f.String("s", "str", "")
+	f.Int("i", 7, "")
+	f.Bool("b", true, "")
+	f.Float64("x", 2.5, "")
+	f.Duration("d", time.Second, "")
+
+	get := map[string]func(string) (interface{}, error){
+		"GetString":   func(n string) (interface{}, error) { return f.GetString(n) },
+		"GetInt":      func(n string) (interface{}, error) { return f.GetInt(n) },
+		"GetBool":     func(n string) (interface{}, error) { return f.GetBool(n) },
+		"GetFloat64":  func(n string) (interface{}, error) { return f.GetFloat64(n) },
+		"GetDuration": func(n string) (interface{}, error) { return f.GetDuration(n) },
+	}
+	tests := []struct {
+		fn   string
+		name string
+		want interface{}
+		ok   bool
+	}{
+		{"GetString", "s", "str", true},
+		{"GetInt", "i", 7, true},
+		{"GetBool", "b", true, true},
+		{"GetFloat64", "x", 2.5, true},
+		{"GetDuration", "d", time.Second, true},
+		{"GetString", "i", "", false},
+		{"GetInt", "s", 0, false},
+		{"GetBool", "s", false, false},
+		{"GetFloat64", "i", 0.0, false},
+		{"GetDuration", "i", time.Duration(0), false},
+		{"GetString", "missing", "", false},
+	}
+	for _, tt := range tests {
+		got, err := get[tt.fn](tt.name)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s(%q) error = %v, want ok=%v", tt.fn, tt.name, err, tt.ok)
+		}
+		if got != tt.want {
+			t.Errorf("%s(%q) = %#v, want %#v", tt.fn, tt.name, got, tt.want)
+		}
+	}
+
+	if err := f.Set("i", "9"); err != nil {
+		t.Fatal(err)
+	}
+	if i, _ := f.GetInt("i"); i != 9 {
+		t.Errorf("GetInt after Set = %d, want 9", i)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		overwrite bool
+		a, b, c   string
+	}{
+		{false, "base", "override", "base"},
+		{true, "override", "override", "base"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		a := f.String("a", "", "")
+		b := f.String("b", "", "")
+		c := f.String("c", "", "")
+		f.Set("a", "base")
+		f.Set("c", "base")
+
+		other := newTestSet()
+		other.String("a", "", "")
+		other.String("b", "", "")
+		other.String("c", "default", "")
+		other.Set("a", "override")
+		other.Set("b", "override")
+
+		if err := f.Merge(other, tt.overwrite); err != nil {
+			t.Fatalf("overwrite=%v: Merge: %v", tt.overwrite, err)
+		}
+		if *a != tt.a || *b != tt.b || *c != tt.c {
+			t.Errorf("overwrite=%v: a, b, c = %q, %q, %q, want %q, %q, %q",
+				tt.overwrite, *a, *b, *c, tt.a, tt.b, tt.c)
+		}
+		if !f.Changed("b") {
+			t.Errorf("overwrite=%v: b not changed by Merge", tt.overwrite)
+		}
+		if got := f.Source("b"); got != "set" {
+			t.Errorf("overwrite=%v: Source(b) = %q, want %q", tt.overwrite, got, "set")
+		}
+	}
+
+	f := newTestSet()
+	n := f.Int("n", 1, "")
+	other := newTestSet()
+	other.String("n", "", "")
+	other.String("unknown", "", "")
+	other.Set("n", "x")
+	other.Set("unknown", "y")
+	err := f.Merge(other, true)
+	if err == nil || !strings.Contains(err.Error(), "config n:") || !strings.Contains(err.Error(), "config unknown:") {
+		t.Errorf("Merge error = %v, want errors for n and unknown", err)
+	}
+	if *n != 1 {
+		t.Errorf("n = %d after a failed merge, want 1", *n)
+	}
+}
+
+func TestClone(t *testing.T) {
+	f := newTestSet()
+	s := f.String("s", "def", "a string")
+	list := f.StringSlice("list", []string{"x"}, "")
+	f.Int("n", 1, "")
+	f.Alias("str", "s")
+	f.SetValidator("n", func(v Value) error {
+		if v.(Getter).Get().(int) < 0 {
+			return errors.New("negative")
+		}
+		return nil
+	})
+	f.Set("s", "orig")
+	f.Set("list", "a,b")
+
+	c := f.Clone()
+	for _, name := range []string{"s", "list", "n"} {
+		if got, want := c.Lookup(name).Value.String(), f.Lookup(name).Value.String(); got != want {
+			t.Errorf("clone %s = %q, want %q", name, got, want)
+		}
+		if got, want := c.Changed(name), f.Changed(name); got != want {
+			t.Errorf("clone Changed(%s) = %v, want %v", name, got, want)
+		}
+	}
+	if got := c.Lookup("s").DefValue; got != "def" {
+		t.Errorf("clone DefValue = %q, want %q", got, "def")
+	}
+
+	tests := []struct {
+		name, value string
+	}{
+		{"s", "changed"},
+		{"str", "via alias"},
+		{"list", "c"},
+		{"n", "5"},
+	}
+	for _, tt := range tests {
+		if err := c.Set(tt.name, tt.value); err != nil {
+			t.Errorf("clone Set(%s, %q): %v", tt.name, tt.value, err)
+		}
+	}
+	if *s != "orig" || !reflect.DeepEqual(*list, []string{"a", "b"}) || f.Lookup("n").Value.String() != "1" {
+		t.Errorf("original changed through its clone: s=%q list=%q n=%s", *s, *list, f.Lookup("n").Value)
+	}
+	if got := c.Lookup("s").Value.String(); got != "via alias" {
+		t.Errorf("clone s = %q, want %q", got, "via alias")
+	}
+	if err := c.Set("n", "-1"); err == nil {
+		t.Error("clone did not keep the validator")
+	}
+	if f.Changed("n") {
+		t.Error("setting the clone marked the original changed")
+	}
+
+	// A clone of a subcommand does not write to its parent.
+	root := newTestSet()
+	verbose := root.Bool("verbose", false, "")
+	root.Set("verbose", "true")
+	sub := root.Subcommand("run")
+	sub.Int("jobs", 1, "")
+	sc := sub.Clone()
+	if err := sc.Parse([]string{"-verbose=false", "-jobs=4"}); err != nil {
+		t.Fatal(err)
+	}
+	if !*verbose || !root.Changed("verbose") {
+		t.Error("parsing a clone of a subcommand changed its parent")
+	}
+	if got := sc.Lookup("verbose").Value.String(); got != "false" {
+		t.Errorf("clone verbose = %s, want false", got)
+	}
+	if got := sub.Lookup("jobs").Value.String(); got != "1" {
+		t.Errorf("original jobs = %s, want 1", got)
+	}
+
+	// A Value that cannot be copied makes Clone panic.
+	p := newTestSet()
+	p.Var(plainFunc(func(string) error { return nil }), "fn", "")
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Clone of a set with a non-pointer custom Value did not panic")
+			}
+		}()
+		p.Clone()
+	}()
+}
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- string(b)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestSetUnknown(t *testing.T) {
+	tests := []struct {
+		allow bool
+		ok    bool
+	}{
+		{false, false},
+		{true, true},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.AllowUnknown = tt.allow
+		var err error
+		out := captureStdout(t, func() { err = f.Set("typo", "value") })
+		if (err == nil) != tt.ok {
+			t.Errorf("AllowUnknown=%v: Set error = %v, want ok=%v", tt.allow, err, tt.ok)
+		}
+		if err != nil && err.Error() != "no such config typo" {
+			t.Errorf("AllowUnknown=%v: Set error = %q, want %q", tt.allow, err, "no such config typo")
+		}
+		if out != "" {
+			t.Errorf("AllowUnknown=%v: Set wrote %q to stdout", tt.allow, out)
+		}
+		config := f.Lookup("typo")
+		if (config != nil) != tt.allow {
+			t.Errorf("AllowUnknown=%v: Lookup(typo) = %v", tt.allow, config)
+		}
+		if config != nil {
+			if got := config.Value.(Getter).Get(); got != "value" {
+				t.Errorf("AllowUnknown=%v: typo = %#v, want %q", tt.allow, got, "value")
+			}
+		}
+
+		g := newTestSet()
+		g.AllowUnknown = tt.allow
+		err = g.LoadFrom(strings.NewReader("typo = value\n"))
+		if (err == nil) != tt.ok {
+			t.Errorf("AllowUnknown=%v: LoadFrom error = %v, want ok=%v", tt.allow, err, tt.ok)
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.conf")
+	tests := []struct {
+		logger bool
+		want   []string
+	}{
+		{false, nil},
+		{true, []string{"wrote config to " + name, "loading config from " + name}},
+	}
+	for _, tt := range tests {
+		var log bytes.Buffer
+		f := NewConfigSet(name, ContinueOnError)
+		f.SetOutput(io.Discard)
+		if tt.logger {
+			f.SetLogger(&log)
+		}
+		f.String("s", "v", "a string")
+		out := captureStdout(t, func() {
+			if err := f.Save(); err != nil {
+				t.Errorf("Save: %v", err)
+			}
+			if err := f.Load(); err != nil {
+				t.Errorf("Load: %v", err)
+			}
+			f.Set("s", "w")
+		})
+		if out != "" {
+			t.Errorf("logger=%v: wrote %q to stdout", tt.logger, out)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(log.String(), want) {
+				t.Errorf("logger=%v: log %q does not contain %q", tt.logger, log.String(), want)
+			}
+		}
+	}
+}
+
+func TestAlias(t *testing.T) {
+	tests := []struct {
+		args []string
+	}{
+		{[]string{"-t=5s"}},
+		{[]string{"-timeout=5s"}},
+		{[]string{"-t", "5s"}},
+		{[]string{"--timeout", "5s"}},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		d := f.Duration("timeout", time.Second, "how long to wait")
+		if err := f.Alias("t", "timeout"); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Parse(tt.args); err != nil {
+			t.Errorf("Parse(%q): %v", tt.args, err)
+		}
+		if *d != 5*time.Second {
+			t.Errorf("Parse(%q): timeout = %v, want 5s", tt.args, *d)
+		}
+	}
+
+	f := newTestSet()
+	d := f.Duration("timeout", time.Second, "how long to wait")
+	f.Int("n", 0, "")
+	if err := f.Alias("t", "timeout"); err != nil {
+		t.Fatal(err)
+	}
+	if f.Lookup("t") != f.Lookup("timeout") {
+		t.Error("Lookup of the alias and the config differ")
+	}
+	if err := f.Set("t", "3s"); err != nil || *d != 3*time.Second {
+		t.Errorf("Set(t, 3s): err = %v, timeout = %v", err, *d)
+	}
+	if err := f.LoadFrom(strings.NewReader("t = 4s\n")); err != nil || *d != 4*time.Second {
+		t.Errorf("LoadFrom(t = 4s): err = %v, timeout = %v", err, *d)
+	}
+
+	errTests := []struct {
+		alias, canonical string
+	}{
+		{"x", "missing"},
+		{"n", "timeout"},
+		{"t", "n"},
+	}
+	for _, tt := range errTests {
+		if err := f.Alias(tt.alias, tt.canonical); err == nil {
+			t.Errorf("Alias(%q, %q) succeeded", tt.alias, tt.canonical)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := f.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains("\n"+buf.String(), "\nt=") || strings.Count(buf.String(), "timeout=") != 1 {
+		t.Errorf("SaveTo wrote the alias:\n%s", buf.String())
+	}
+	var names []string
+	f.VisitAll(func(c *Config) { names = append(names, c.Name) })
+	if !reflect.DeepEqual(names, []string{"n", "timeout"}) {
+		t.Errorf("VisitAll visited %q, want [n timeout]", names)
+	}
+}
+
+func TestDeprecate(t *testing.T) {
+	var log bytes.Buffer
+	f := newTestSet()
+	f.SetLogger(&log)
+	n := f.Int("new", 0, "the new name")
+	f.Int("old", 0, "the old name")
+	f.Alias("legacy", "new")
+	f.Deprecate("old", "use new")
+	f.Deprecate("legacy", "use new instead")
+
+	tests := []struct {
+		set  func() error
+		want int
+	}{
+		{func() error { return f.Set("old", "1") }, 1},
+		{func() error { return f.Set("old", "2") }, 1},
+		{func() error { return f.Parse([]string{"-old=3", "-old=4"}) }, 1},
+		{func() error { return f.LoadFrom(strings.NewReader("old = 5\n")) }, 1},
+		{func() error { return f.Set("new", "6") }, 1},
+		{func() error { return f.Set("legacy", "7") }, 2},
+		{func() error { return f.Set("legacy", "8") }, 2},
+	}
+	for i, tt := range tests {
+		if err := tt.set(); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if got := strings.Count(log.String(), "is deprecated"); got != tt.want {
+			t.Errorf("%d: %d warnings, want %d:\n%s", i, got, tt.want, log.String())
+		}
+	}
+	if *n != 8 {
+		t.Errorf("new = %d, want 8", *n)
+	}
+	if !strings.Contains(log.String(), "config old is deprecated: use new\n") {
+		t.Errorf("log %q does not contain the message for old", log.String())
+	}
+
+	for _, hide := range []bool{false, true} {
+		f.HideDeprecated = hide
+		var buf bytes.Buffer
+		f.PrintDefaults(&buf)
+		if got := strings.Contains(buf.String(), "the old name"); got == hide {
+			t.Errorf("HideDeprecated=%v: PrintDefaults lists old = %v:\n%s", hide, got, buf.String())
+		}
+		if !strings.Contains(buf.String(), "the new name") {
+			t.Errorf("HideDeprecated=%v: PrintDefaults does not list new:\n%s", hide, buf.String())
+		}
+	}
+}
+
+func TestParsed(t *testing.T) {
+	tests := []struct {
+		args []string
+	}{
+		{nil},
+		{[]string{"-n=1"}},
+		{[]string{"-n=x"}},
+		{[]string{"-undefined"}},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.Int("n", 0, "")
+		if f.Parsed() {
+			t.Errorf("Parsed() = true before Parse(%q)", tt.args)
+		}
+		if err := f.LoadFrom(strings.NewReader("n = 2\n")); err != nil {
+			t.Fatal(err)
+		}
+		if f.Parsed() {
+			t.Errorf("Parsed() = true after LoadFrom")
+		}
+		f.Parse(tt.args)
+		if !f.Parsed() {
+			t.Errorf("Parsed() = false after Parse(%q)", tt.args)
+		}
+	}
+}
+
+func TestValueErrorNamesConfig(t *testing.T) {
+	tests := []struct {
+		how string
+		set func(f *ConfigSet) error
+	}{
+		{"Set", func(f *ConfigSet) error { return f.Set("port", "http") }},
+		{"Parse", func(f *ConfigSet) error { return f.Parse([]string{"-port=http"}) }},
+		{"LoadFrom", func(f *ConfigSet) error { return f.LoadFrom(strings.NewReader("port = http\n")) }},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.Int("port", 80, "")
+		err := tt.set(f)
+		if err == nil {
+			t.Errorf("%s: no error", tt.how)
+			continue
+		}
+		if !strings.Contains(err.Error(), `invalid value "http" for config -port`) {
+			t.Errorf("%s: error %q does not name the config", tt.how, err)
+		}
+		var numErr *strconv.NumError
+		if !errors.As(err, &numErr) || !errors.Is(err, strconv.ErrSyntax) {
+			t.Errorf("%s: error %q does not wrap the strconv error", tt.how, err)
+		}
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		insensitive bool
+		name        string
+		ok          bool
+	}{
+		{false, "MaxConns", true},
+		{false, "maxconns", false},
+		{false, "MAXCONNS", false},
+		{true, "MaxConns", true},
+		{true, "maxconns", true},
+		{true, "MAXCONNS", true},
+		{true, "max-conns", false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.CaseInsensitive = tt.insensitive
+		n := f.Int("MaxConns", 1, "")
+		if got := f.Lookup(tt.name) != nil; got != tt.ok {
+			t.Errorf("CaseInsensitive=%v: Lookup(%q) found = %v, want %v", tt.insensitive, tt.name, got, tt.ok)
+		}
+		if err := f.Set(tt.name, "2"); (err == nil) != tt.ok {
+			t.Errorf("CaseInsensitive=%v: Set(%q) error = %v, want ok=%v", tt.insensitive, tt.name, err, tt.ok)
+		}
+		if err := f.Parse([]string{"-" + tt.name + "=3"}); (err == nil) != tt.ok {
+			t.Errorf("CaseInsensitive=%v: Parse(-%s) error = %v, want ok=%v", tt.insensitive, tt.name, err, tt.ok)
+		}
+		if err := f.LoadFrom(strings.NewReader(tt.name + " = 4\n")); (err == nil) != tt.ok {
+			t.Errorf("CaseInsensitive=%v: LoadFrom(%s) error = %v, want ok=%v", tt.insensitive, tt.name, err, tt.ok)
+		}
+		want := 1
+		if tt.ok {
+			want = 4
+		}
+		if *n != want {
+			t.Errorf("CaseInsensitive=%v: %s: MaxConns = %d, want %d", tt.insensitive, tt.name, *n, want)
+		}
+
+		var buf bytes.Buffer
+		if err := f.SaveTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(buf.String(), "MaxConns=") {
+			t.Errorf("CaseInsensitive=%v: SaveTo wrote %q, want the name as defined", tt.insensitive, buf.String())
+		}
+	}
+}
+
+func TestQuoteValues(t *testing.T) {
+	values := []string{
+		"plain",
+		"  spaced  ",
+		"a#b",
+		"a ; b",
+		`say "hi"`,
+		`"quoted"`,
+		`back\slash`,
+		`trailing\`,
+		"a=b",
+		"tab\there",
+		"",
+		"私はパイ",
+	}
+	for _, val := range values {
+		f := newTestSet()
+		f.String("s", "", "a string")
+		f.Set("s", val)
+		g := newTestSet()
+		s := g.String("s", "default", "a string")
+		roundTrip(t, f, g)
+		if *s != val {
+			t.Errorf("round trip of %q gave %q", val, *s)
+		}
+	}
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`s = "say \"hi\""`, `say "hi"`},
+		{`s = "a\tb"`, "a\tb"},
+		{`s = ""`, ""},
+		{`s = a"b"`, `a"b"`},
+		{`s = "unterminated`, `"unterminated`},
+		{`s = "bad \q escape"`, `bad \q escape`},
+		{`s = "  spaced  "  # comment`, "  spaced  "},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		s := f.String("s", "", "")
+		if err := f.LoadFrom(strings.NewReader(tt.line + "\n")); err != nil {
+			t.Errorf("LoadFrom(%q): %v", tt.line, err)
+		}
+		if *s != tt.want {
+			t.Errorf("LoadFrom(%q): s = %q, want %q", tt.line, *s, tt.want)
+		}
+	}
+}
+
+func TestMultiLineValues(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"s = one \\\n  two \\\n  three\n", "one two three"},
+		{"s = \"\"\"\nline one\nline two\nline three\"\"\"\n", "line one\nline two\nline three"},
+		{"s = \"\"\"line one\n  line two\n\"\"\"  # comment\n", "line one\n  line two\n"},
+		{"s = \"\"\"a # not a comment\nb = not a key\"\"\"\nn = 1\n", "a # not a comment\nb = not a key"},
+		{"s = \"\"\"one line\"\"\"\n", "one line"},
+		{"# s = \"\"\"\ns = plain\n", "plain"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		s := f.String("s", "", "")
+		f.Int("n", 0, "")
+		if err := f.LoadFrom(strings.NewReader(tt.file)); err != nil {
+			t.Errorf("LoadFrom(%q): %v", tt.file, err)
+		}
+		if *s != tt.want {
+			t.Errorf("LoadFrom(%q): s = %q, want %q", tt.file, *s, tt.want)
+		}
+	}
+
+	values := []string{
+		"-----BEGIN KEY-----\nMIIB\nabcd\n-----END KEY-----\n",
+		"SELECT *\n  FROM t\n  WHERE a = 1",
+		"ends in a quote\n\"",
+		"has \"\"\" inside\nit",
+		"crlf\r\nline",
+	}
+	for _, val := range values {
+		f := newTestSet()
+		f.String("s", "", "a string")
+		f.Set("s", val)
+		g := newTestSet()
+		s := g.String("s", "", "a string")
+		roundTrip(t, f, g)
+		if *s != val {
+			t.Errorf("round trip of %q gave %q", val, *s)
+		}
+	}
+
+	f := newTestSet()
+	f.String("s", "", "")
+	if err := f.LoadFrom(strings.NewReader("s = \"\"\"never\nclosed\n")); err == nil {
+		t.Error("unterminated block accepted")
+	}
+}
+
+func TestGetter(t *testing.T) {
+	f := newTestSet()
+	tests := []struct {
+		name string
+		p    interface{}
+	}{
+		{"bool", f.Bool("bool", true, "")},
+		{"int", f.Int("int", 1, "")},
+		{"int64", f.Int64("int64", 2, "")},
+		{"int32", f.Int32("int32", 3, "")},
+		{"uint", f.Uint("uint", 4, "")},
+		{"uint64", f.Uint64("uint64", 5, "")},
+		{"uint32", f.Uint32("uint32", 6, "")},
+		{"string", f.String("string", "s", "")},
+		{"float64", f.Float64("float64", 1.5, "")},
+		{"float32", f.Float32("float32", 2.5, "")},
+		{"duration", f.Duration("duration", time.Second, "")},
+		{"strings", f.StringSlice("strings", []string{"a"}, "")},
+		{"ints", f.IntSlice("ints", []int{1}, "")},
+		{"durations", f.DurationSlice("durations", []time.Duration{time.Minute}, "")},
+		{"map", f.StringMap("map", map[string]string{"k": "v"}, "")},
+		{"ip", f.IP("ip", net.IPv4(127, 0, 0, 1), "")},
+		{"time", f.Time("time", time.Unix(0, 0).UTC(), "", "")},
+		{"bytes", f.Bytes("bytes", 1024, "")},
+		{"enum", f.Enum("enum", []string{"a", "b"}, "a", "")},
+		{"level", f.Level("level", map[string]int{"info": 1}, 1, "")},
+		{"hex", f.BytesHex("hex", 0, []byte{1, 2}, "")},
+		{"url", f.URL("url", nil, "")},
+		{"count", f.Count("count", "")},
+	}
+	for _, tt := range tests {
+		config := f.Lookup(tt.name)
+		g, ok := config.Value.(Getter)
+		if !ok {
+			t.Errorf("%s: Value %T is not a Getter", tt.name, config.Value)
+			continue
+		}
+		want := reflect.ValueOf(tt.p).Elem().Interface()
+		if got := g.Get(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Get() = %#v, want %#v", tt.name, got, want)
+		}
+	}
+
+	// A Value need not be a Getter; its String form stands in for Get.
+	var v plainValue
+	f.Var(&v, "plain", "")
+	f.Set("plain", "x")
+	if _, ok := f.Lookup("plain").Value.(Getter); ok {
+		t.Error("plainValue is a Getter")
+	}
+	if got, err := f.GetString("plain"); got != "x" || err != nil {
+		t.Errorf("GetString(plain) = %q, %v; want x", got, err)
+	}
+	if got := f.Snapshot()["plain"]; got != "x" {
+		t.Errorf("Snapshot()[plain] = %#v, want x", got)
+	}
+}
+
+func TestChangedIsDefault(t *testing.T) {
+	tests := []struct {
+		name      string
+		set       string
+		changed   bool
+		isDefault bool
+	}{
+		{"left", "", false, true},
+		{"same", "8080", true, true},
+		{"other", "9090", true, false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.Int("port", 8080, "")
+		if tt.set != "" {
+			if err := f.Set("port", tt.set); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := f.Changed("port"); got != tt.changed {
+			t.Errorf("%s: Changed = %v, want %v", tt.name, got, tt.changed)
+		}
+		if got := f.Lookup("port").IsDefault(); got != tt.isDefault {
+			t.Errorf("%s: IsDefault = %v, want %v", tt.name, got, tt.isDefault)
+		}
+	}
+	if newTestSet().Changed("missing") {
+		t.Error("Changed(missing) = true")
+	}
+}
+
+func TestNegation(t *testing.T) {
+	tests := []struct {
+		enable bool
+		args   []string
+		color  bool
+		ok     bool
+	}{
+		{true, []string{"-no-color"}, false, true},
+		{true, []string{"-color"}, true, true},
+		{true, []string{"-color=false"}, false, true},
+		{true, []string{"-no-color=false"}, true, false},
+		{true, []string{"-no-color", "-color"}, true, true},
+		{true, []string{"-no-name=x"}, true, false},
+		{true, []string{"-no-color=x"}, true, false},
+		{false, []string{"-no-color"}, true, false},
+		{false, []string{"-color=false"}, false, true},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.EnableNegation = tt.enable
+		color := f.Bool("color", true, "")
+		f.String("name", "", "")
+		err := f.Parse(tt.args)
+		if (err == nil) != tt.ok {
+			t.Errorf("EnableNegation=%v: Parse(%q) error = %v, want ok=%v", tt.enable, tt.args, err, tt.ok)
+		}
+		if *color != tt.color {
+			t.Errorf("EnableNegation=%v: Parse(%q): color = %v, want %v", tt.enable, tt.args, *color, tt.color)
+		}
+	}
+
+	f := newTestSet()
+	f.EnableNegation = true
+	literal := f.Bool("no-cache", false, "")
+	f.Bool("cache", true, "")
+	if err := f.Parse([]string{"-no-cache"}); err != nil || !*literal {
+		t.Errorf("Parse(-no-cache) with a config of that name: no-cache = %v, %v", *literal, err)
+	}
+}
+
+func TestFileRefs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	secret := write("secret", "hunter2\n")
+	crlf := write("crlf", "line\r\n")
+	port := write("port", "8080\n")
+	multi := write("multi", "one\ntwo\n\n")
+
+	tests := []struct {
+		allow bool
+		name  string
+		value string
+		want  string
+		ok    bool
+	}{
+		{true, "s", "@" + secret, "hunter2", true},
+		{true, "s", "@" + crlf, "line", true},
+		{true, "s", "@" + multi, "one\ntwo\n", true},
+		{true, "n", "@" + port, "8080", true},
+		{true, "s", "@@literal", "@literal", true},
+		{true, "s", "plain", "plain", true},
+		{true, "s", "@" + filepath.Join(dir, "missing"), "old", false},
+		{false, "s", "@" + secret, "@" + secret, true},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.AllowFileRefs = tt.allow
+		f.String("s", "old", "")
+		f.Int("n", 0, "")
+		err := f.LoadFrom(strings.NewReader(tt.name + " = " + tt.value + "\n"))
+		if (err == nil) != tt.ok {
+			t.Errorf("AllowFileRefs=%v: load %s = %q: error = %v, want ok=%v", tt.allow, tt.name, tt.value, err, tt.ok)
+		}
+		if got := f.Lookup(tt.name).Value.String(); got != tt.want {
+			t.Errorf("AllowFileRefs=%v: load %s = %q: got %q, want %q", tt.allow, tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+// configNames returns the names of the configs set by the lines of s, in
+// order.
+func configNames(s string) []string {
+	var names []string
+	for _, line := range strings.Split(s, "\n") {
+		if name, _, ok := strings.Cut(line, "="); ok {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	return names
+}
+
+func TestSaveOrder(t *testing.T) {
+	tests := []struct {
+		order SaveOrder
+		want  []string
+	}{
+		{SortedOrder, []string{"alpha", "mid", "zeta"}},
+		{DefinitionOrder, []string{"zeta", "alpha", "mid"}},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.SaveOrder = tt.order
+		f.String("zeta", "z", "last letter")
+		f.String("alpha", "a", "first letter")
+		f.String("mid", "m", "middle")
+		var buf bytes.Buffer
+		if err := f.SaveTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		names := configNames(buf.String())
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("SaveOrder %d: wrote %q, want %q:\n%s", tt.order, names, tt.want, buf.String())
+		}
+	}
+}
+
+func TestVisitAllOrdered(t *testing.T) {
+	f := newTestSet()
+	for _, name := range []string{"zeta", "alpha", "mid", "beta"} {
+		f.String(name, "", "")
+	}
+	f.Alias("z", "zeta")
+	f.Set("mid", "set")
+
+	var ordered, sorted []string
+	f.VisitAllOrdered(func(c *Config) { ordered = append(ordered, c.Name) })
+	f.VisitAll(func(c *Config) { sorted = append(sorted, c.Name) })
+	if want := []string{"zeta", "alpha", "mid", "beta"}; !reflect.DeepEqual(ordered, want) {
+		t.Errorf("VisitAllOrdered visited %q, want %q", ordered, want)
+	}
+	if want := []string{"alpha", "beta", "mid", "zeta"}; !reflect.DeepEqual(sorted, want) {
+		t.Errorf("VisitAll visited %q, want %q", sorted, want)
+	}
+
+	if err := f.Unset("alpha"); err != nil {
+		t.Fatal(err)
+	}
+	f.String("alpha", "", "")
+	ordered = nil
+	f.VisitAllOrdered(func(c *Config) { ordered = append(ordered, c.Name) })
+	if want := []string{"zeta", "mid", "beta", "alpha"}; !reflect.DeepEqual(ordered, want) {
+		t.Errorf("after redefining alpha, VisitAllOrdered visited %q, want %q", ordered, want)
+	}
+}
+
+func TestSubcommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		selected string
+		verbose  bool
+		port     int
+		level    string
+		subLevel string
+		rest     []string
+		ok       bool
+	}{
+		{[]string{"-verbose", "serve", "-port=80"}, "serve", true, 80, "global", "default", nil, true},
+		{[]string{"serve", "-verbose", "-port", "81", "extra"}, "serve", true, 81, "global", "default", []string{"extra"}, true},
+		{[]string{"serve", "-level=sub"}, "serve", false, 8080, "global", "sub", nil, true},
+		{[]string{"-level=top", "migrate"}, "migrate", false, 8080, "top", "default", nil, true},
+		{[]string{"migrate", "-port=80"}, "migrate", false, 8080, "global", "default", nil, false},
+		{[]string{"-verbose"}, "", true, 8080, "global", "default", nil, true},
+		{[]string{"other"}, "", false, 8080, "global", "default", []string{"other"}, true},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		verbose := f.Bool("verbose", false, "")
+		level := f.String("level", "global", "")
+		serve := f.Subcommand("serve")
+		serve.SetOutput(io.Discard)
+		port := serve.Int("port", 8080, "")
+		subLevel := serve.String("level", "default", "")
+		migrate := f.Subcommand("migrate")
+		migrate.SetOutput(io.Discard)
+
+		err := f.Parse(tt.args)
+		if (err == nil) != tt.ok {
+			t.Errorf("Parse(%q) error = %v, want ok=%v", tt.args, err, tt.ok)
+		}
+		if !tt.ok {
+			continue
+		}
+		selected := ""
+		if s := f.Selected(); s != nil {
+			selected = s.Name()
+		}
+		if selected != tt.selected {
+			t.Errorf("Parse(%q): Selected = %q, want %q", tt.args, selected, tt.selected)
+		}
+		if *verbose != tt.verbose || *port != tt.port || *level != tt.level || *subLevel != tt.subLevel {
+			t.Errorf("Parse(%q): verbose=%v port=%d level=%q serve level=%q, want %v, %d, %q, %q",
+				tt.args, *verbose, *port, *level, *subLevel, tt.verbose, tt.port, tt.level, tt.subLevel)
+		}
+		args := f.Args()
+		if s := f.Selected(); s != nil {
+			args = s.Args()
+		}
+		if len(args) == 0 {
+			args = nil
+		}
+		if !reflect.DeepEqual(args, tt.rest) {
+			t.Errorf("Parse(%q): Args = %q, want %q", tt.args, args, tt.rest)
+		}
+	}
+
+	f := newTestSet()
+	verbose := f.Bool("verbose", false, "")
+	serve := f.Subcommand("serve")
+	if f.Subcommand("serve") != serve {
+		t.Error("Subcommand returned a new set for an existing subcommand")
+	}
+	if serve.Lookup("verbose") != f.Lookup("verbose") {
+		t.Error("Lookup in the subcommand does not fall through to the parent")
+	}
+	if err := serve.Set("verbose", "true"); err != nil || !*verbose {
+		t.Errorf("Set(verbose) in the subcommand: verbose = %v, %v", *verbose, err)
+	}
+}
+
+func TestCommentChars(t *testing.T) {
+	file := "; an INI comment\n# a hash comment\nn = 1 ; trailing\ns = a;b\nt = x # y\n"
+	tests := []struct {
+		chars string
+		n     int
+		s, t  string
+		ok    bool
+	}{
+		{"", 1, "a;b", "x", true},
+		{"#;", 1, "a;b", "x", true},
+		{";", 1, "a;b", "x # y", true},
+		{"#", 0, "a;b", "x", false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.CommentChars = tt.chars
+		n := f.Int("n", 0, "")
+		s := f.String("s", "", "")
+		tv := f.String("t", "", "")
+		err := f.LoadFrom(strings.NewReader(file))
+		if (err == nil) != tt.ok {
+			t.Errorf("CommentChars %q: LoadFrom error = %v, want ok=%v", tt.chars, err, tt.ok)
+		}
+		if *n != tt.n || *s != tt.s || *tv != tt.t {
+			t.Errorf("CommentChars %q: n=%d s=%q t=%q, want n=%d s=%q t=%q", tt.chars, *n, *s, *tv, tt.n, tt.s, tt.t)
+		}
+
+		var buf bytes.Buffer
+		if err := f.SaveTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), "; ") {
+			t.Errorf("CommentChars %q: SaveTo wrote a ';' comment:\n%s", tt.chars, buf.String())
+		}
+	}
+}
+
+func TestWhitespaceAndAlign(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"s=v", "v"},
+		{"s = v", "v"},
+		{"\ts\t=\tv", "v"},
+		{"  s   =    v   ", "v"},
+		{"s\t=\tv\t# comment", "v"},
+		{"\t\ts =\t\"  padded  \"", "  padded  "},
+		{"s =", ""},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		s := f.String("s", "default", "")
+		if err := f.LoadFrom(strings.NewReader(tt.line + "\n")); err != nil {
+			t.Errorf("LoadFrom(%q): %v", tt.line, err)
+		}
+		if *s != tt.want {
+			t.Errorf("LoadFrom(%q): s = %q, want %q", tt.line, *s, tt.want)
+		}
+	}
+
+	f := newTestSet()
+	f.AlignValues = true
+	f.String("a", "1", "short")
+	f.String("longer/name", "2", "long")
+	var buf bytes.Buffer
+	if err := f.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	col := -1
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		i := strings.Index(line, "=")
+		if col == -1 {
+			col = i
+		} else if i != col {
+			t.Errorf("AlignValues: '=' not aligned:\n%s", buf.String())
+			break
+		}
+	}
+	g := newTestSet()
+	a := g.String("a", "", "")
+	long := g.String("longer/name", "", "")
+	if err := g.LoadFrom(&buf); err != nil || *a != "1" || *long != "2" {
+		t.Errorf("reading aligned output: a=%q longer/name=%q, %v", *a, *long, err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		existing string
+		want     string
+	}{
+		{
+			"# shared file\nother = kept   # owned elsewhere\nn = 1  # mine\n\ns = old\n",
+			"# shared file\nother = kept   # owned elsewhere\nn = 2  # mine\n\ns = old\nadded=new # a new config\n",
+		},
+		{
+			"n = 2 # unchanged\n",
+			"n = 2 # unchanged\nadded=new # a new config\ns=old # a string\n",
+		},
+		{
+			"",
+			"added=new # a new config\nn=2 # a number\ns=old # a string\n",
+		},
+	}
+	for _, tt := range tests {
+		name := filepath.Join(t.TempDir(), "shared.conf")
+		if tt.existing != "" {
+			if err := os.WriteFile(name, []byte(tt.existing), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f := newTestSet()
+		f.Int("n", 1, "a number")
+		f.String("s", "old", "a string")
+		f.String("added", "new", "a new config")
+		f.Set("n", "2")
+		if err := f.Update(name); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("Update of %q wrote\n%s\nwant\n%s", tt.existing, b, tt.want)
+		}
+	}
+}
+
+func TestDefaultValue(t *testing.T) {
+	f := newTestSet()
+	f.Duration("d", 90*time.Second, "")
+	f.Int("n", 7, "")
+	f.Bool("b", true, "")
+	f.String("s", "str", "")
+	f.StringSlice("list", []string{"a", "b"}, "")
+	f.Func("func", "", func(string) error { return nil })
+	tests := []struct {
+		name string
+		set  string
+		want interface{}
+	}{
+		{"d", "1s", 90 * time.Second},
+		{"n", "1", 7},
+		{"b", "false", true},
+		{"s", "changed", "str"},
+		{"list", "c", []string{"a", "b"}},
+		{"func", "x", nil},
+	}
+	for _, tt := range tests {
+		if err := f.Set(tt.name, tt.set); err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Lookup(tt.name).DefaultValue(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: DefaultValue() = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLenientBool(t *testing.T) {
+	tests := []struct {
+		in      string
+		lenient bool
+		want    bool
+		ok      bool
+	}{
+		{"true", false, true, true},
+		{"0", false, false, true},
+		{"yes", false, false, false},
+		{"yes", true, true, true},
+		{"YES", true, true, true},
+		{"On", true, true, true},
+		{"enabled", true, true, true},
+		{"no", true, false, true},
+		{"OFF", true, false, true},
+		{"Disabled", true, false, true},
+		{"F", true, false, true},
+		{"maybe", true, false, false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.LenientBool = tt.lenient
+		b := f.Bool("b", false, "")
+		f.String("s", "", "")
+		err := f.LoadFrom(strings.NewReader("b = " + tt.in + "\ns = " + tt.in + "\n"))
+		if (err == nil) != tt.ok {
+			t.Errorf("LenientBool=%v: load b = %s: error = %v, want ok=%v", tt.lenient, tt.in, err, tt.ok)
+		}
+		if *b != tt.want {
+			t.Errorf("LenientBool=%v: load b = %s: b = %v, want %v", tt.lenient, tt.in, *b, tt.want)
+		}
+		if got := f.Lookup("s").Value.String(); got != tt.in {
+			t.Errorf("LenientBool=%v: string config s = %q, want %q", tt.lenient, got, tt.in)
+		}
+		if got := f.Lookup("b").Value.String(); got != strconv.FormatBool(tt.want) {
+			t.Errorf("LenientBool=%v: String() = %q, want %v", tt.lenient, got, tt.want)
+		}
+	}
+}
+
+func TestTryVar(t *testing.T) {
+	f := newTestSet()
+	f.Int("n", 1, "")
+	f.Alias("num", "n")
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"m", true},
+		{"n", false},
+		{"num", false},
+		{"m", false},
+	}
+	for _, tt := range tests {
+		err := f.TryVar(newIntValue(5, new(int)), tt.name, "")
+		if (err == nil) != tt.ok {
+			t.Errorf("TryVar(%q) error = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+	if got := f.Lookup("n").Value.String(); got != "1" {
+		t.Errorf("n = %s after a failed redefinition, want 1", got)
+	}
+
+	handling := []struct {
+		h     ErrorHandling
+		panic bool
+	}{
+		{ContinueOnError, false},
+		{PanicOnError, true},
+	}
+	for _, tt := range handling {
+		g := NewConfigSet("", tt.h)
+		var out bytes.Buffer
+		g.SetOutput(&out)
+		g.Int("n", 1, "")
+		panicked := func() (p bool) {
+			defer func() { p = recover() != nil }()
+			g.Int("n", 2, "")
+			return false
+		}()
+		if panicked != tt.panic {
+			t.Errorf("handling %d: redefinition panicked = %v, want %v", tt.h, panicked, tt.panic)
+		}
+		if !strings.Contains(out.String(), "config redefined: n") {
+			t.Errorf("handling %d: output %q does not report the redefinition", tt.h, out.String())
+		}
+		if got := g.Lookup("n").DefValue; got != "1" {
+			t.Errorf("handling %d: DefValue = %s, want the first definition's 1", tt.h, got)
+		}
+	}
+
+	// Under ContinueOnError, the variable of a redefinition is left unbound.
+	g := newTestSet()
+	first := g.Int("n", 1, "")
+	second := g.Int("n", 2, "")
+	if err := g.Set("n", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if *first != 3 || *second != 2 {
+		t.Errorf("after Set: first = %d, second = %d; want 3 and the unbound 2", *first, *second)
+	}
+}
+
+func TestLoadBytesSaveBytes(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		s    string
+		want string
+	}{
+		{"", 1, "a", "n=1 # a number\ns=a # a string\n"},
+		{"n = 2\n", 2, "a", "n=2 # a number\ns=a # a string\n"},
+		{"s = \"b c\"\nn=3", 3, "b c", "n=3 # a number\ns=b c # a string\n"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		n := f.Int("n", 1, "a number")
+		s := f.String("s", "a", "a string")
+		if err := f.LoadBytes([]byte(tt.in)); err != nil {
+			t.Errorf("LoadBytes(%q): %v", tt.in, err)
+		}
+		if *n != tt.n || *s != tt.s {
+			t.Errorf("LoadBytes(%q): n=%d s=%q, want n=%d s=%q", tt.in, *n, *s, tt.n, tt.s)
+		}
+		b, err := f.SaveBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("SaveBytes after LoadBytes(%q) = %q, want %q", tt.in, b, tt.want)
+		}
+		if got := f.Source("n"); tt.n != 1 && got != "reader" {
+			t.Errorf("LoadBytes(%q): Source(n) = %q, want %q", tt.in, got, "reader")
+		}
+	}
+
+	f := newTestSet()
+	f.Int("n", 1, "")
+	if err := f.LoadBytes([]byte("n = x\n")); err == nil {
+		t.Error("LoadBytes of a bad value succeeded")
+	}
+}
+
+func TestDump(t *testing.T) {
+	f := newTestSet()
+	f.String("name", "me", "who")
+	f.Int("n", 1, "how many")
+	want := fmt.Sprintf("%-20s = %s # %s\n%-20s = %s # %s\n", "n", "1", "how many", "name", "me", "who")
+	if got := f.Dump(); got != want {
+		t.Errorf("Dump() = %q, want %q", got, want)
+	}
+	f.Set("n", "2")
+	if got := f.Dump(); !strings.Contains(got, "= 2 # how many") {
+		t.Errorf("Dump() after Set = %q, want the new value", got)
+	}
+	out := captureStdout(t, f.Print)
+	if out != f.Dump() {
+		t.Errorf("Print wrote %q, want the Dump %q", out, f.Dump())
+	}
+}
+
+func TestSetSecret(t *testing.T) {
+	tests := []struct {
+		redact bool
+		save   func(f *ConfigSet) error
+		want   string
+	}{
+		{false, (*ConfigSet).Save, "password=hunter2"},
+		{true, (*ConfigSet).Save, "password=****"},
+		{true, (*ConfigSet).SaveWithSecrets, "password=hunter2"},
+	}
+	for _, tt := range tests {
+		name := filepath.Join(t.TempDir(), "secret.conf")
+		f := NewConfigSet(name, ContinueOnError)
+		f.SetOutput(io.Discard)
+		f.RedactSecrets = tt.redact
+		p := f.String("password", "", "the password")
+		f.String("user", "me", "the user")
+		f.SetSecret("password")
+		f.Set("password", "hunter2")
+
+		if *p != "hunter2" {
+			t.Errorf("password = %q, want the real value", *p)
+		}
+		if got, _ := f.GetString("password"); got != "hunter2" {
+			t.Errorf("GetString(password) = %q, want the real value", got)
+		}
+		if dump := f.Dump(); strings.Contains(dump, "hunter2") || !strings.Contains(dump, "****") || !strings.Contains(dump, "= me") {
+			t.Errorf("Dump() = %q, want password redacted and user shown", dump)
+		}
+		if err := tt.save(f); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), tt.want+" ") || !strings.Contains(string(b), "user=me") {
+			t.Errorf("RedactSecrets=%v: saved\n%s\nwant a line starting %q", tt.redact, b, tt.want)
+		}
+	}
+}
+
+func TestLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	defaults := filepath.Join(dir, "defaults.conf")
+	env := filepath.Join(dir, "prod.conf")
+	local := filepath.Join(dir, "local.conf")
+	missing := filepath.Join(dir, "missing.conf")
+	for name, content := range map[string]string{
+		defaults: "a = 1\nb = 1\nc = 1\n",
+		env:      "b = 2\nc = 2\n",
+		local:    "c = 3\n",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		files   []string
+		require bool
+		a, b, c int
+		from    [3]string
+		ok      bool
+	}{
+		{[]string{defaults, env, local}, false, 1, 2, 3, [3]string{defaults, env, local}, true},
+		{[]string{local, env, defaults}, false, 1, 1, 1, [3]string{defaults, defaults, defaults}, true},
+		{[]string{defaults, missing, local}, false, 1, 1, 3, [3]string{defaults, defaults, local}, true},
+		{[]string{defaults, missing, local}, true, 1, 1, 3, [3]string{defaults, defaults, local}, false},
+		{nil, false, 0, 0, 0, [3]string{}, true},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.RequireFiles = tt.require
+		a, b, c := f.Int("a", 0, ""), f.Int("b", 0, ""), f.Int("c", 0, "")
+		err := f.LoadFiles(tt.files...)
+		if (err == nil) != tt.ok {
+			t.Errorf("LoadFiles(%q) RequireFiles=%v: error = %v, want ok=%v", tt.files, tt.require, err, tt.ok)
+		}
+		if *a != tt.a || *b != tt.b || *c != tt.c {
+			t.Errorf("LoadFiles(%q): a=%d b=%d c=%d, want %d %d %d", tt.files, *a, *b, *c, tt.a, tt.b, tt.c)
+		}
+		for i, name := range []string{"a", "b", "c"} {
+			if got := f.LoadedFrom(name); got != tt.from[i] {
+				t.Errorf("LoadFiles(%q): LoadedFrom(%s) = %q, want %q", tt.files, name, got, tt.from[i])
+			}
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.conf")
+	if err := os.WriteFile(name, []byte("file = 1\ncmd = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GFC_SOURCE_ENV", "2")
+	f := NewConfigSet(name, ContinueOnError)
+	f.SetOutput(io.Discard)
+	for _, n := range []string{"default", "file", "cmd", "env", "set", "reader", "json"} {
+		f.Int(n, 0, "")
+	}
+	f.BindEnv("env", "GFC_SOURCE_ENV")
+	if err := f.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Parse([]string{"-cmd=3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Resolve(); err != nil {
+		t.Fatal(err)
+	}
+	f.Set("set", "4")
+	f.LoadFrom(strings.NewReader("reader = 5\n"))
+	f.LoadJSON(strings.NewReader(`{"json": 6}`))
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"default", "default"},
+		{"file", "file:" + name},
+		{"cmd", "command-line"},
+		{"env", "env:GFC_SOURCE_ENV"},
+		{"set", "set"},
+		{"reader", "reader"},
+		{"json", "json"},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		if got := f.Source(tt.name); got != tt.want {
+			t.Errorf("Source(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetBatch(t *testing.T) {
+	tests := []struct {
+		values   map[string]string
+		cert     string
+		key      string
+		port     int
+		errNames string
+	}{
+		{map[string]string{"cert": "new.pem", "key": "new.key"}, "new.pem", "new.key", 443, ""},
+		{map[string]string{"cert": "new.pem", "key": "new.key", "port": "x"}, "old.pem", "old.key", 443, "-port"},
+		{map[string]string{"cert": "new.pem", "key": "bad.pem"}, "old.pem", "old.key", 443, "-key"},
+		{map[string]string{"cert": "new.pem", "missing": "1"}, "old.pem", "old.key", 443, "-missing"},
+		{map[string]string{"port": "8443"}, "old.pem", "old.key", 8443, ""},
+		{map[string]string{}, "old.pem", "old.key", 443, ""},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		cert := f.String("cert", "old.pem", "")
+		key := f.String("key", "old.key", "")
+		port := f.Int("port", 443, "")
+		f.SetValidator("key", func(v Value) error {
+			if !strings.HasSuffix(v.String(), ".key") {
+				return errors.New("not a key file")
+			}
+			return nil
+		})
+		err := f.SetBatch(tt.values)
+		if (err == nil) != (tt.errNames == "") {
+			t.Errorf("SetBatch(%v) error = %v, want error naming %q", tt.values, err, tt.errNames)
+		}
+		if err != nil && !strings.Contains(err.Error(), "config "+tt.errNames) {
+			t.Errorf("SetBatch(%v) error = %q, want it to name %q", tt.values, err, tt.errNames)
+		}
+		if *cert != tt.cert || *key != tt.key || *port != tt.port {
+			t.Errorf("SetBatch(%v): cert=%q key=%q port=%d, want %q %q %d", tt.values, *cert, *key, *port, tt.cert, tt.key, tt.port)
+		}
+		if err != nil && (f.Changed("cert") || f.Changed("key") || f.Changed("port")) {
+			t.Errorf("SetBatch(%v) failed but marked configs changed", tt.values)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	f := newTestSet()
+	for _, name := range []string{"db/host", "db/port", "http/port", "log/level", "timeout"} {
+		f.String(name, "v", "")
+	}
+	f.Alias("dbhost", "db/host")
+	f.Tag("db/port", "db")
+	f.Tag("dbhost", "db", "network")
+	f.Tag("http/port", "network", "network")
+	f.Tag("timeout", "db", "network")
+
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{"db", []string{"db/host", "db/port", "timeout"}},
+		{"network", []string{"db/host", "http/port", "timeout"}},
+		{"none", nil},
+	}
+	for _, tt := range tests {
+		var names []string
+		f.VisitTagged(tt.tag, func(c *Config) { names = append(names, c.Name) })
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("VisitTagged(%q) visited %q, want %q", tt.tag, names, tt.want)
+		}
+
+		var buf bytes.Buffer
+		if err := f.SaveTagged(&buf, tt.tag); err != nil {
+			t.Fatal(err)
+		}
+		names = configNames(buf.String())
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("SaveTagged(%q) wrote %q, want %q", tt.tag, names, tt.want)
+		}
+
+		names = configNames(f.DumpTagged(tt.tag))
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("DumpTagged(%q) listed %q, want %q", tt.tag, names, tt.want)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	errStop := errors.New("stop")
+	tests := []struct {
+		stopAt  string
+		visited []string
+	}{
+		{"", []string{"a", "b", "c"}},
+		{"a", []string{"a"}},
+		{"b", []string{"a", "b"}},
+		{"c", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		for _, name := range []string{"c", "a", "b"} {
+			f.Int(name, 0, "")
+		}
+		var visited []string
+		err := f.Walk(func(c *Config) error {
+			visited = append(visited, c.Name)
+			if c.Name == tt.stopAt {
+				return errStop
+			}
+			return nil
+		})
+		var want error
+		if tt.stopAt != "" {
+			want = errStop
+		}
+		if err != want {
+			t.Errorf("stop at %q: Walk error = %v, want %v", tt.stopAt, err, want)
+		}
+		if !reflect.DeepEqual(visited, tt.visited) {
+			t.Errorf("stop at %q: visited %q, want %q", tt.stopAt, visited, tt.visited)
+		}
+	}
+}
+
+func TestLoadBOMAndCRLF(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"LF", "first = one\ns = \"two\"\nn = 3 # comment\n"},
+		{"CRLF", "first = one\r\ns = \"two\"\r\nn = 3 # comment\r\n"},
+		{"BOM", "\ufefffirst = one\ns = \"two\"\nn = 3 # comment\n"},
+		{"BOM CRLF", "\ufefffirst = one\r\ns = \"two\"\r\nn = 3 # comment\r\n"},
+		{"CRLF no final newline", "first = one\r\ns = two\r\nn = 3"},
+		{"CRLF continuation", "first = o\\\r\nne\r\ns = two\r\nn = 3\r\n"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		first := f.String("first", "", "")
+		s := f.String("s", "", "")
+		n := f.Int("n", 0, "")
+		if err := f.LoadBytes([]byte(tt.in)); err != nil {
+			t.Errorf("%s: LoadBytes: %v", tt.name, err)
+		}
+		if *first != "one" || *s != "two" || *n != 3 {
+			t.Errorf("%s: first=%q s=%q n=%d, want one two 3", tt.name, *first, *s, *n)
+		}
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	tests := []struct {
+		raw  bool
+		val  string
+		line string
+	}{
+		{false, "tab\there", `s="tab\there"`},
+		{false, "one\ntwo", "s=\"\"\"\none\ntwo\"\"\""},
+		{false, "cr\r\nlf", `s="cr\r\nlf"`},
+		{false, `back\slash`, `s=back\slash`},
+		{false, `say "hi"`, `s="say \"hi\""`},
+		{false, `C:\dir\`, `s="C:\\dir\\"`},
+		{true, `C:\new\table`, `s=C:\new\table`},
+		{true, `  C:\new  `, `s="  C:\new  "`},
+		{true, "tab\there", "s=\"tab\there\""},
+		{true, "one\ntwo", "s=\"\"\"\none\ntwo\"\"\""},
+		{true, `say "hi"`, `s="say \"hi\""`},
+		{true, `C:\dir\`, `s="C:\\dir\\"`},
+		{true, `a #\`, `s="a #\\"`},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.RawQuotes = tt.raw
+		f.String("s", "", "")
+		f.Set("s", tt.val)
+		var buf bytes.Buffer
+		if err := f.SaveTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(buf.String(), " # \n"); got != tt.line {
+			t.Errorf("RawQuotes=%v: saved %q as %q, want %q", tt.raw, tt.val, got, tt.line)
+		}
+		g := newTestSet()
+		g.RawQuotes = tt.raw
+		s := g.String("s", "", "")
+		if err := g.LoadFrom(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if *s != tt.val {
+			t.Errorf("RawQuotes=%v: round trip of %q gave %q", tt.raw, tt.val, *s)
+		}
+	}
+
+	f := newTestSet()
+	f.RawQuotes = true
+	s := f.String("s", "", "")
+	if err := f.LoadBytes([]byte(`s = "C:\temp\new"` + "\n")); err != nil || *s != `C:\temp\new` {
+		t.Errorf("RawQuotes: s = %q, %v; want the backslashes kept", *s, err)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	orig := Configuration
+	fresh := newTestSet()
+	old := SetDefault(fresh)
+	if old != orig || Configuration != fresh {
+		t.Fatalf("SetDefault returned %p and installed %p, want %p and %p", old, Configuration, orig, fresh)
+	}
+	n := Int("gfc-test-n", 1, "")
+	if err := Set("gfc-test-n", "2"); err != nil || *n != 2 {
+		t.Errorf("Set through the fresh set: n = %d, %v", *n, err)
+	}
+	if fresh.Lookup("gfc-test-n") == nil {
+		t.Error("Int did not define the config in the installed set")
+	}
+	defer func(args []string) { os.Args = args }(os.Args)
+	os.Args = []string{"cmd", "-gfc-test-n=3", "-gfc-undefined"}
+	if err := Parse(); !errors.Is(err, ErrUnknownConfig) {
+		t.Errorf("Parse() with a ContinueOnError set installed = %v, want ErrUnknownConfig", err)
+	}
+	os.Args = []string{"cmd", "-gfc-test-n=3", "arg"}
+	if err := Parse(); err != nil || *n != 3 || NArg() != 1 {
+		t.Errorf("Parse(): n = %d, NArg() = %d, %v", *n, NArg(), err)
+	}
+	if got := SetDefault(old); got != fresh {
+		t.Errorf("restoring returned %p, want %p", got, fresh)
+	}
+	if Configuration != orig || Lookup("gfc-test-n") != nil {
+		t.Error("the original set was not restored untouched")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		ok   bool
+	}{
+		{`-name="a b" -x=1`, []string{"-name=a b", "-x=1"}, true},
+		{"  -x=1\t-y \n 2  ", []string{"-x=1", "-y", "2"}, true},
+		{`-name='a "b" \c'`, []string{`-name=a "b" \c`}, true},
+		{`-name="say \"hi\" \\ \n"`, []string{`-name=say "hi" \ \n`}, true},
+		{`-name=a\ b c\"d`, []string{"-name=a b", `c"d`}, true},
+		{`-name="" ''`, []string{"-name=", ""}, true},
+		{"", nil, true},
+		{`-name="a b`, nil, false},
+		{`-name='a b`, nil, false},
+		{`-name=a\`, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("splitArgs(%q) error = %v, want ok=%v", tt.in, err, tt.ok)
+		}
+		if tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseString(t *testing.T) {
+	f := newTestSet()
+	name := f.String("name", "", "")
+	x := f.Int("x", 0, "")
+	if err := f.ParseString(`-name="a b" -x=1 rest 'of it'`); err != nil {
+		t.Fatal(err)
+	}
+	if *name != "a b" || *x != 1 {
+		t.Errorf("name=%q x=%d, want \"a b\" 1", *name, *x)
+	}
+	if want := []string{"rest", "of it"}; !reflect.DeepEqual(f.Args(), want) {
+		t.Errorf("Args() = %q, want %q", f.Args(), want)
+	}
+	if err := f.ParseString(`-name="unterminated`); err == nil || !strings.Contains(err.Error(), "unterminated double quote") {
+		t.Errorf("ParseString of an unterminated quote: error = %v", err)
+	}
+}
+
+func TestPrefixMatch(t *testing.T) {
+	tests := []struct {
+		allow   bool
+		arg     string
+		set     string
+		errText string
+	}{
+		{true, "-tim=5s", "timeout", ""},
+		{true, "-timeout=5s", "timeout", ""},
+		{true, "-ti=5s", "", "ambiguous config: -ti could be -tick, -timeout"},
+		{true, "-t=5s", "", ""},
+		{true, "-tic=5s", "tick", ""},
+		{true, "-x=5s", "", "not defined"},
+		{false, "-tim=5s", "", "not defined"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.AllowPrefixMatch = tt.allow
+		timeout := f.Duration("timeout", time.Second, "")
+		tick := f.Duration("tick", time.Second, "")
+		f.Duration("t", time.Second, "")
+		err := f.Parse([]string{tt.arg})
+		if tt.errText == "" && err != nil || tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)) {
+			t.Errorf("AllowPrefixMatch=%v: Parse(%s) error = %v, want %q", tt.allow, tt.arg, err, tt.errText)
+		}
+		if got := *timeout == 5*time.Second; got != (tt.set == "timeout") {
+			t.Errorf("AllowPrefixMatch=%v: Parse(%s): timeout = %v", tt.allow, tt.arg, *timeout)
+		}
+		if got := *tick == 5*time.Second; got != (tt.set == "tick") {
+			t.Errorf("AllowPrefixMatch=%v: Parse(%s): tick = %v", tt.allow, tt.arg, *tick)
+		}
+	}
+
+	f := newTestSet()
+	f.AllowPrefixMatch = true
+	f.Int("verbose", 0, "")
+	f.Int("version", 0, "")
+	lookups := []struct {
+		name string
+		want string
+	}{
+		{"verb", "verbose"},
+		{"vers", "version"},
+		{"ver", ""},
+		{"version", "version"},
+		{"z", ""},
+	}
+	for _, tt := range lookups {
+		got := ""
+		if config := f.Lookup(tt.name); config != nil {
+			got = config.Name
+		}
+		if got != tt.want {
+			t.Errorf("Lookup(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExpandConfigs(t *testing.T) {
+	t.Setenv("GFC_EXPAND_HOME", "/home/me")
+	tests := []struct {
+		file   string
+		env    bool
+		logs   string
+		errors string
+	}{
+		{"base = /var/app\nlogs = ${base}/logs\n", false, "/var/app/logs", ""},
+		{"logs = ${base}/logs\nbase = /var/app\n", false, "/var/app/logs", ""},
+		{"logs = ${base}/logs\n", false, "/srv/logs", ""},
+		{"base = ${root}/app\nroot = /opt\nlogs = ${base}/logs\n", false, "/opt/app/logs", ""},
+		{"logs = $${base} costs $5\n", false, "${base} costs $5", ""},
+		{"logs = ${base\n", false, "${base", ""},
+		{"logs = ${nope}\n", false, "", "reference to undefined config nope"},
+		{"logs = ${GFC_EXPAND_HOME}/${base}\n", true, "/home/me//srv", ""},
+		{"logs = $${x}\n", true, "${x}", ""},
+		{"base = ${logs}\nlogs = ${base}\n", false, "", "config reference cycle: logs -> base -> logs"},
+		{"root = ${root}\nlogs = ${root}\n", false, "", "config reference cycle: root -> root"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.ExpandConfigs = true
+		f.ExpandEnv = tt.env
+		f.String("base", "/srv", "")
+		f.String("root", "/", "")
+		logs := f.String("logs", "", "")
+		err := f.LoadFrom(strings.NewReader(tt.file))
+		if tt.errors == "" {
+			if err != nil {
+				t.Errorf("LoadFrom(%q): %v", tt.file, err)
+			}
+			if *logs != tt.logs {
+				t.Errorf("LoadFrom(%q): logs = %q, want %q", tt.file, *logs, tt.logs)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.errors) {
+			t.Errorf("LoadFrom(%q) error = %v, want %q", tt.file, err, tt.errors)
+		}
+	}
+
+	f := newTestSet()
+	logs := f.String("logs", "", "")
+	f.String("base", "/srv", "")
+	if err := f.LoadFrom(strings.NewReader("logs = ${base}/logs\n")); err != nil || *logs != "${base}/logs" {
+		t.Errorf("without ExpandConfigs: logs = %q, %v", *logs, err)
+	}
+}
+
+func TestLoadAllowed(t *testing.T) {
+	file := "port = 8080\nadmin = true\npw = stolen\n"
+	tests := []struct {
+		allowed []string
+		reject  bool
+		port    int
+		admin   bool
+		pw      string
+		ok      bool
+	}{
+		{[]string{"port"}, false, 8080, false, "secret", true},
+		{[]string{"p"}, false, 8080, false, "secret", true},
+		{[]string{"port", "admin", "pw"}, false, 8080, true, "stolen", true},
+		{nil, false, 80, false, "secret", true},
+		{[]string{"port"}, true, 8080, false, "secret", false},
+		{[]string{"port", "admin", "pw"}, true, 8080, true, "stolen", true},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.RejectDisallowed = tt.reject
+		port := f.Int("port", 80, "")
+		admin := f.Bool("admin", false, "")
+		pw := f.String("pw", "secret", "")
+		f.Alias("p", "port")
+		err := f.LoadAllowed(strings.NewReader(file), tt.allowed)
+		if (err == nil) != tt.ok {
+			t.Errorf("LoadAllowed(%q) RejectDisallowed=%v: error = %v, want ok=%v", tt.allowed, tt.reject, err, tt.ok)
+		}
+		if err != nil && !strings.Contains(err.Error(), "config admin may not be set by this file") {
+			t.Errorf("LoadAllowed(%q): error = %q, want it to name admin", tt.allowed, err)
+		}
+		if *port != tt.port || *admin != tt.admin || *pw != tt.pw {
+			t.Errorf("LoadAllowed(%q): port=%d admin=%v pw=%q, want %d %v %q", tt.allowed, *port, *admin, *pw, tt.port, tt.admin, tt.pw)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	f := newTestSet()
+	n := f.Int("n", 1, "")
+	f.Int("m", 1, "")
+	if err := f.Parse([]string{"-n=5"}); err != nil {
+		t.Fatal(err)
+	}
+	f.Set("m", "6")
+	f.Reset()
+
+	if f.Parsed() {
+		t.Error("Parsed() = true after Reset")
+	}
+	if f.NConfig() != 0 || f.Changed("n") || f.Changed("m") {
+		t.Errorf("after Reset: NConfig = %d, Changed(n) = %v, Changed(m) = %v", f.NConfig(), f.Changed("n"), f.Changed("m"))
+	}
+	var visited []string
+	f.Visit(func(c *Config) { visited = append(visited, c.Name) })
+	if visited != nil {
+		t.Errorf("Visit after Reset visited %q", visited)
+	}
+	for _, name := range []string{"n", "m"} {
+		if got := f.Source(name); got != "default" {
+			t.Errorf("Source(%s) after Reset = %q, want %q", name, got, "default")
+		}
+	}
+	if *n != 5 || f.Lookup("m").Value.String() != "6" {
+		t.Errorf("Reset changed values: n = %d, m = %s", *n, f.Lookup("m").Value)
+	}
+	if f.Lookup("n") == nil || f.Lookup("m") == nil {
+		t.Error("Reset removed config definitions")
+	}
+
+	f.Set("n", "7")
+	if !f.Changed("n") || f.NConfig() != 1 {
+		t.Errorf("Set after Reset: Changed(n) = %v, NConfig = %d", f.Changed("n"), f.NConfig())
+	}
+}
+
+func TestHas(t *testing.T) {
+	f := newTestSet()
+	f.Int("n", 0, "")
+	f.Int("m", 0, "")
+	f.Alias("count", "n")
+	f.Section("db").String("host", "", "")
+	f.Set("m", "1")
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"n", true},
+		{"m", true},
+		{"count", true},
+		{"db.host", true},
+		{"x", false},
+		{"", false},
+		{"db", false},
+	}
+	for _, tt := range tests {
+		if got := f.Has(tt.name); got != tt.want {
+			t.Errorf("Has(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !f.Section("db").Has("host") {
+		t.Error(`Section("db").Has("host") = false, want true`)
+	}
+
+	f.AllowPrefixMatch = true
+	if f.Has("db.h") {
+		t.Error(`Has("db.h") = true under AllowPrefixMatch, want false`)
+	}
+}
+
+func TestCommentDefaults(t *testing.T) {
+	f := newTestSet()
+	f.CommentDefaults = true
+	f.String("host", "localhost", "server host")
+	f.Int("port", 80, "server port")
+	f.String("motd", "line one\nline two", "message of the day")
+	f.Set("port", "8080")
+	var buf bytes.Buffer
+	if err := f.SaveTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		`# host=localhost # server host`,
+		`port=8080 # server port`,
+		`# motd="line one\nline two" # message of the day`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("SaveTo wrote\n%s\nwant a line %q", out, line)
+		}
+	}
+	if strings.Contains(out, "# port=") {
+		t.Errorf("SaveTo commented out the set config -port:\n%s", out)
+	}
+
+	g := newTestSet()
+	g.String("host", "", "")
+	g.Int("port", 0, "")
+	g.String("motd", "", "")
+	if err := g.LoadFrom(strings.NewReader(out)); err != nil {
+		t.Fatal(err)
+	}
+	if g.Changed("host") || g.Changed("motd") || !g.Changed("port") {
+		t.Errorf("after LoadFrom, Changed(host, motd, port) = %v, %v, %v; want false, false, true",
+			g.Changed("host"), g.Changed("motd"), g.Changed("port"))
+	}
+}
+
+func TestParseSelfContained(t *testing.T) {
+	osArgs := append([]string(nil), os.Args...)
+	globalArgs := Configuration.Args()
+	globalN := Configuration.NConfig()
+
+	f := newTestSet()
+	x := f.Int("x", 0, "")
+	if err := f.Parse([]string{"-x=1", "pos"}); err != nil {
+		t.Fatal(err)
+	}
+	if *x != 1 {
+		t.Errorf("x = %d, want 1", *x)
+	}
+	if f.NArg() != 1 || f.Arg(0) != "pos" {
+		t.Errorf("Args() = %q, want [pos]", f.Args())
+	}
+	if !reflect.DeepEqual(os.Args, osArgs) {
+		t.Errorf("Parse changed os.Args to %q", os.Args)
+	}
+	if !reflect.DeepEqual(Configuration.Args(), globalArgs) || Configuration.NConfig() != globalN {
+		t.Error("Parse changed the command-line config set")
+	}
+	if Configuration.Lookup("x") != nil {
+		t.Error("Parse defined -x in the command-line config set")
+	}
+}
+
+func TestErrUnknownConfig(t *testing.T) {
+	tests := []struct {
+		args    []string
+		unknown bool
+	}{
+		{[]string{"-notdefined"}, true},
+		{[]string{"--notdefined=1"}, true},
+		{[]string{"-n=1", "-x"}, true},
+		{[]string{"-n=x"}, false},
+		{[]string{"-n"}, false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.Int("n", 0, "")
+		usages := 0
+		f.Usage = func() { usages++ }
+		err := f.Parse(tt.args)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", tt.args)
+			continue
+		}
+		if got := errors.Is(err, ErrUnknownConfig); got != tt.unknown {
+			t.Errorf("Parse(%q) = %v; errors.Is(err, ErrUnknownConfig) = %v, want %v", tt.args, err, got, tt.unknown)
+		}
+		if tt.unknown && usages != 1 {
+			t.Errorf("Parse(%q) called Usage %d times, want 1", tt.args, usages)
+		}
+		if tt.unknown && f.NArg() != 0 {
+			t.Errorf("Parse(%q) left Args() = %q, want the unknown config not taken as an argument", tt.args, f.Args())
+		}
+	}
+
+	p := NewConfigSet("", PanicOnError)
+	p.SetOutput(io.Discard)
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrUnknownConfig) {
+			t.Errorf("Parse under PanicOnError panicked with %v, want ErrUnknownConfig", err)
+		}
+	}()
+	p.Parse([]string{"-notdefined"})
+	t.Error("Parse under PanicOnError did not panic")
+}
+
+func TestErrHelp(t *testing.T) {
+	for _, arg := range []string{"-h", "-help", "--help"} {
+		f := newTestSet()
+		f.Int("n", 0, "")
+		usages := 0
+		f.Usage = func() { usages++ }
+		if err := f.Parse([]string{arg}); err != ErrHelp {
+			t.Errorf("Parse(%q) = %v, want ErrHelp", arg, err)
+		}
+		if usages != 1 {
+			t.Errorf("Parse(%q) called Usage %d times, want 1", arg, usages)
+		}
+	}
+
+	// A config named help is parsed like any other.
+	f := newTestSet()
+	help := f.Bool("help", false, "")
+	f.Usage = func() { t.Error("Parse called Usage for a defined -help") }
+	if err := f.Parse([]string{"-help"}); err != nil {
+		t.Fatalf("Parse(-help) with -help defined: %v", err)
+	}
+	if !*help {
+		t.Error("help = false, want true")
+	}
+	f.Usage = func() {}
+	if err := f.Parse([]string{"-h"}); err != ErrHelp {
+		t.Errorf("Parse(-h) with only -help defined = %v, want ErrHelp", err)
+	}
+
+	// Neither is taken as a prefix of a defined config.
+	g := newTestSet()
+	g.AllowPrefixMatch = true
+	g.Int("hostport", 0, "")
+	g.Usage = func() {}
+	if err := g.Parse([]string{"-h"}); err != ErrHelp {
+		t.Errorf("Parse(-h) under AllowPrefixMatch = %v, want ErrHelp", err)
+	}
+}
+
+func TestVisitUnset(t *testing.T) {
+	tests := []struct {
+		set  []string
+		want []string
+	}{
+		{nil, []string{"a", "b", "c", "db.host"}},
+		{[]string{"b"}, []string{"a", "c", "db.host"}},
+		{[]string{"db.host", "a"}, []string{"b", "c"}},
+		{[]string{"a", "b", "c", "db.host"}, nil},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.String("c", "", "")
+		f.String("a", "", "")
+		f.String("b", "", "")
+		f.Section("db").String("host", "", "")
+		for _, name := range tt.set {
+			if err := f.Set(name, "x"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var unset, set []string
+		f.VisitUnset(func(config *Config) { unset = append(unset, config.Name) })
+		f.Visit(func(config *Config) { set = append(set, config.Name) })
+		if !reflect.DeepEqual(unset, tt.want) {
+			t.Errorf("after setting %q, VisitUnset visited %q, want %q", tt.set, unset, tt.want)
+		}
+		if len(set)+len(unset) != 4 {
+			t.Errorf("after setting %q, Visit and VisitUnset visited %q and %q, want every config once", tt.set, set, unset)
+		}
+	}
+}
+
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"max-conns", "max-conns"},
+		{"max_conns", "max-conns"},
+		{"MAX_CONNS", "max-conns"},
+		{"maxConns", "max-conns"},
+		{"MaxConns", "max-conns"},
+		{"http2Proxy", "http2-proxy"},
+		{"db.maxConns", "db.max-conns"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeKey(tt.name); got != tt.want {
+			t.Errorf("NormalizeKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKeyNormalizer(t *testing.T) {
+	for _, name := range []string{"max-conns", "max_conns", "maxConns", "MAX_CONNS"} {
+		f := newTestSet()
+		f.KeyNormalizer = NormalizeKey
+		n := f.Int("max_conns", 0, "")
+		if config := f.Lookup(name); config == nil || config.Name != "max_conns" {
+			t.Errorf("Lookup(%q) = %v, want -max_conns", name, config)
+		}
+		if err := f.Set(name, "1"); err != nil || *n != 1 {
+			t.Errorf("Set(%q, 1): n = %d, %v", name, *n, err)
+		}
+		if err := f.Parse([]string{"-" + name + "=2"}); err != nil || *n != 2 {
+			t.Errorf("Parse(-%s=2): n = %d, %v", name, *n, err)
+		}
+		if err := f.LoadBytes([]byte(name + "=3\n")); err != nil || *n != 3 {
+			t.Errorf("LoadBytes(%s=3): n = %d, %v", name, *n, err)
+		}
+		if !f.Changed(name) || !f.Has(name) {
+			t.Errorf("Changed(%q), Has(%q) = %v, %v; want true, true", name, name, f.Changed(name), f.Has(name))
+		}
+	}
+
+	f := newTestSet()
+	f.Int("max_conns", 0, "")
+	if f.Lookup("maxConns") != nil {
+		t.Error(`Lookup("maxConns") without KeyNormalizer found -max_conns`)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	f := newTestSet()
+	f.Int("port", 0, "")
+	f.String("host", "", "")
+	f.Int("workers", 0, "")
+	f.Int("other", 0, "")
+	if err := f.LoadBytes([]byte("port=70000\nhost=\nworkers=4\nother=-1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if errs := f.Validate(); errs != nil {
+		t.Errorf("Validate() with no validators = %v, want nil", errs)
+	}
+	f.SetValidator("port", func(v Value) error {
+		if n := v.(Getter).Get().(int); n < 1 || n > 65535 {
+			return fmt.Errorf("port %d out of range", n)
+		}
+		return nil
+	})
+	f.SetValidator("host", func(v Value) error {
+		if v.String() == "" {
+			return errors.New("host is empty")
+		}
+		return nil
+	})
+	f.SetValidator("workers", func(v Value) error {
+		if v.(Getter).Get().(int) < 1 {
+			return errors.New("no workers")
+		}
+		return nil
+	})
+
+	errs := f.Validate()
+	want := []string{"config host: host is empty", "config port: port 70000 out of range"}
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %q, want %q", got, want)
+	}
+
+	f.Set("host", "example.com")
+	f.Set("port", "80")
+	if errs := f.Validate(); errs != nil {
+		t.Errorf("Validate() after fixing the values = %v, want nil", errs)
+	}
+}
+
+func TestLoadFirstFound(t *testing.T) {
+	dir := t.TempDir()
+	missing1 := filepath.Join(dir, "app.conf")
+	missing2 := filepath.Join(dir, "xdg", "app", "app.conf")
+	present := filepath.Join(dir, "etc", "app.conf")
+	later := filepath.Join(dir, "later.conf")
+	unreadable := filepath.Join(dir, "dir.conf")
+	if err := os.MkdirAll(filepath.Dir(present), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(present, []byte("n = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(later, []byte("n = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A directory exists but cannot be read as a file.
+	if err := os.Mkdir(unreadable, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		files   []string
+		want    string
+		n       int
+		wantErr bool
+	}{
+		{[]string{missing1, missing2, present}, present, 1, false},
+		{[]string{missing1, present, later}, present, 1, false},
+		{[]string{later, present}, later, 2, false},
+		{[]string{missing1, missing2}, "", 0, false},
+		{nil, "", 0, false},
+		{[]string{missing1, unreadable, present}, unreadable, 0, true},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		n := f.Int("n", 0, "")
+		got, err := f.LoadFirstFound(tt.files...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("LoadFirstFound(%q) error = %v, want error %v", tt.files, err, tt.wantErr)
+		}
+		if got != tt.want || *n != tt.n {
+			t.Errorf("LoadFirstFound(%q) = %q with n = %d, want %q with n = %d", tt.files, got, *n, tt.want, tt.n)
+		}
+		if tt.want != "" && !tt.wantErr && f.LoadedFrom("n") != tt.want {
+			t.Errorf("LoadFirstFound(%q): LoadedFrom(n) = %q, want %q", tt.files, f.LoadedFrom("n"), tt.want)
+		}
+	}
+}
+
+// plainValue is a Value without a Get method.
+type plainValue string
+
+func (p *plainValue) Set(s string) error { *p = plainValue(s); return nil }
+func (p *plainValue) String() string     { return string(*p) }
+
+// plainFunc is a custom Value that is not a pointer.
+type plainFunc func(string) error
+
+func (fn plainFunc) Set(s string) error { return fn(s) }
+func (fn plainFunc) String() string     { return "" }
+
+// recordValue is a string Value that records each value given to Set.
+type recordValue struct {
+	sets []string
+}
+
+func (r *recordValue) Set(s string) error {
+	r.sets = append(r.sets, s)
+	return nil
+}
+
+func (r *recordValue) Get() interface{} { return r.sets }
+
+func (r *recordValue) String() string {
+	if r == nil || len(r.sets) == 0 {
+		return ""
+	}
+	return r.sets[len(r.sets)-1]
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("GFC_USER", "alice")
+	t.Setenv("GFC_EMPTY", "")
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"${GFC_USER}", "alice"},
+		{"$GFC_USER", "alice"},
+		{"x-${GFC_USER}-y", "x-alice-y"},
+		{"$GFC_EMPTY", ""},
+		{"$GFC_UNSET", ""},
+		{"$$GFC_USER", "$GFC_USER"},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.ExpandEnv = true
+		var r recordValue
+		f.Var(&r, "s", "")
+		if err := f.LoadBytes([]byte("s=" + tt.value + "\n")); err != nil {
+			t.Errorf("LoadBytes(s=%s): %v", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(r.sets, []string{tt.want}) {
+			t.Errorf("LoadBytes(s=%s): Set called with %q, want [%q]", tt.value, r.sets, tt.want)
+		}
+	}
+
+	f := newTestSet()
+	s := f.String("s", "", "")
+	if err := f.LoadBytes([]byte("s=$GFC_USER\n")); err != nil || *s != "$GFC_USER" {
+		t.Errorf("without ExpandEnv: s = %q, %v; want $GFC_USER", *s, err)
+	}
+
+	f = newTestSet()
+	f.ExpandEnv = true
+	f.StrictEnv = true
+	f.String("s", "", "")
+	if err := f.LoadBytes([]byte("s=$GFC_UNSET\n")); err == nil {
+		t.Error("StrictEnv: unset variable accepted")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv("GFC_PORT", "8080")
+	t.Setenv("GFC_HOST", "envhost")
+	t.Setenv("APP_NAME", "envname")
+	t.Setenv("APP_LEVEL", "3")
+
+	f := newTestSet()
+	port := f.Int("port", 0, "")
+	host := f.String("host", "default", "")
+	name := f.String("name", "default", "")
+	level := f.Int("level", 1, "")
+	unbound := f.String("unbound", "default", "")
+	f.BindEnv("port", "GFC_PORT")
+	f.BindEnv("host", "GFC_HOST")
+	f.SetEnvPrefix("app")
+	if err := f.LoadBytes([]byte("host=filehost\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Parse([]string{"-level=5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Resolve(); err != nil {
+		t.Fatal(err)
+	}
+	if *port != 8080 || *host != "filehost" || *name != "envname" || *level != 5 || *unbound != "default" {
+		t.Errorf("port=%d host=%q name=%q level=%d unbound=%q", *port, *host, *name, *level, *unbound)
+	}
+	if got := f.Source("port"); got != "env:GFC_PORT" {
+		t.Errorf(`Source("port") = %q, want "env:GFC_PORT"`, got)
+	}
+	var visited []string
+	f.Visit(func(c *Config) { visited = append(visited, c.Name) })
+	if want := []string{"host", "level", "name", "port"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("Visit saw %q, want %q", visited, want)
+	}
+}
+
+func TestSectionBindEnv(t *testing.T) {
+	t.Setenv("GFC_DB_PORT", "5432")
+	f := newTestSet()
+	db := f.Section("db")
+	port := db.Int("port", 0, "")
+	db.BindEnv("port", "GFC_DB_PORT")
+	if err := f.Resolve(); err != nil {
+		t.Fatal(err)
+	}
+	if *port != 5432 {
+		t.Errorf("port = %d, want 5432", *port)
+	}
+}
+
+// portRange is a validator accepting int values from 1 to 65535.
+func portRange(v Value) error {
+	if n := v.(Getter).Get().(int); n < 1 || n > 65535 {
+		return fmt.Errorf("port %d out of range", n)
+	}
+	return nil
+}
+
+func TestSetValidator(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+		ok    bool
+	}{
+		{"1", 1, true},
+		{"65535", 65535, true},
+		{"0", 80, false},
+		{"65536", 80, false},
+		{"-1", 80, false},
+		{"x", 80, false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		port := f.Int("port", 80, "")
+		f.SetValidator("port", portRange)
+		for _, set := range []func() error{
+			func() error { return f.Set("port", tt.value) },
+			func() error { return f.Parse([]string{"-port=" + tt.value}) },
+			func() error { return f.LoadBytes([]byte("port=" + tt.value + "\n")) },
+		} {
+			*port = 80
+			err := set()
+			if (err == nil) != tt.ok {
+				t.Errorf("setting port to %s: error = %v, want ok=%v", tt.value, err, tt.ok)
+			}
+			if *port != tt.want {
+				t.Errorf("setting port to %s: port = %d, want %d", tt.value, *port, tt.want)
+			}
+			if f.Changed("port") != tt.ok {
+				t.Errorf("setting port to %s: Changed = %v, want %v", tt.value, f.Changed("port"), tt.ok)
+			}
+			f.Reset()
+		}
+	}
+
+	f := newTestSet()
+	db := f.Section("db")
+	port := db.Int("port", 5432, "")
+	db.SetValidator("port", portRange)
+	if err := db.Set("port", "999999"); err == nil {
+		t.Error("section validator did not run")
+	}
+	if *port != 5432 {
+		t.Errorf("port = %d, want 5432", *port)
+	}
+}
+
+// strictInt is an int Value whose Set rejects the empty string, including
+// its own String form when it was defined without a default.
+type strictInt struct {
+	n   int
+	set bool
+}
+
+func (s *strictInt) Set(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	s.n, s.set = n, true
+	return nil
+}
+
+func (s *strictInt) Get() interface{} { return s.n }
+
+func (s *strictInt) String() string {
+	if s == nil || !s.set {
+		return ""
+	}
+	return strconv.Itoa(s.n)
+}
+
+func TestResetToDefaults(t *testing.T) {
+	f := newTestSet()
+	n := f.Int("n", 1, "")
+	s := f.String("s", "default", "")
+	d := f.Duration("d", time.Second, "")
+	list := f.StringSlice("list", []string{"a"}, "")
+	ip := f.IP("ip", nil, "")
+	var strict strictInt
+	f.Var(&strict, "strict", "")
+
+	args := []string{"-n=2", "-s=x", "-d=1m", "-list=b", "-list=c", "-ip=10.0.0.1", "-strict=5"}
+	if err := f.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	f.ResetToDefaults()
+	if *n != 1 || *s != "default" || *d != time.Second || !reflect.DeepEqual(*list, []string{"a"}) || *ip != nil {
+		t.Errorf("after ResetToDefaults: n=%d s=%q d=%v list=%q ip=%v", *n, *s, *d, *list, *ip)
+	}
+	if strict.n != 5 {
+		t.Errorf("strict = %d, want it kept at 5", strict.n)
+	}
+	if got := f.NConfig(); got != 0 {
+		t.Errorf("NConfig() = %d, want 0", got)
+	}
+	if err := f.Set("list", "z"); err != nil || !reflect.DeepEqual(*list, []string{"z"}) {
+		t.Errorf("Set after reset: list = %q, %v; want [z]", *list, err)
+	}
+}
+
+func TestUnset(t *testing.T) {
+	f := newTestSet()
+	n := f.Int("n", 1, "")
+	f.Alias("num", "n")
+	if err := f.Set("n", "2"); err != nil {
+		t.Fatal(err)
+	}
+	f.Unset("n")
+	if f.Has("n") || f.Has("num") || f.Changed("n") {
+		t.Errorf("after Unset: Has(n)=%v Has(num)=%v Changed(n)=%v", f.Has("n"), f.Has("num"), f.Changed("n"))
+	}
+	if err := f.Set("n", "3"); err == nil {
+		t.Error("Set of an unset config succeeded")
+	}
+	if *n != 2 {
+		t.Errorf("orphaned n = %d, want 2", *n)
+	}
+
+	s := f.String("n", "x", "")
+	if err := f.Set("num", "y"); err == nil {
+		t.Error("alias survived Unset")
+	}
+	if err := f.Set("n", "y"); err != nil || *s != "y" {
+		t.Errorf("redefined n = %q, %v; want y", *s, err)
+	}
+
+	db := f.Section("db")
+	db.Int("port", 5432, "")
+	db.Unset("port")
+	if f.Has("db.port") {
+		t.Error("Unset on a section left db.port defined")
+	}
+}
+
+func TestOnChange(t *testing.T) {
+	f := newTestSet()
+	f.Int("n", 1, "")
+	var calls []string
+	f.OnChange("n", func(old, new Value) {
+		calls = append(calls, "a:"+old.String()+"->"+new.String())
+	})
+	f.OnChange("n", func(old, new Value) {
+		calls = append(calls, "b:"+old.String()+"->"+new.String())
+	})
+	for _, v := range []string{"2", "2", "x", "3"} {
+		f.Set("n", v)
+	}
+	want := []string{"a:1->2", "b:1->2", "a:2->3", "b:2->3"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+
+	db := f.Section("db")
+	db.String("host", "a", "")
+	calls = nil
+	db.OnChange("host", func(old, new Value) {
+		calls = append(calls, old.String()+"->"+new.String())
+	})
+	if err := db.Set("host", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a->b"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("section calls = %q, want %q", calls, want)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{{"-h"}, {"-help"}, {"-undefined"}} {
+		f := newTestSet()
+		var buf bytes.Buffer
+		f.SetOutput(&buf)
+		f.Duration("timeout", 30*time.Second, "how long to `wait`")
+		called := 0
+		f.Usage = func() {
+			called++
+			// Usage may use the set; this must not deadlock.
+			f.Has("timeout")
+			f.Changed("timeout")
+			f.Source("timeout")
+			f.PrintDefaults(f.Output())
+		}
+		done := make(chan error)
+		go func() { done <- f.Parse(args) }()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("Parse(%q) succeeded", args)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Parse(%q) deadlocked calling Usage", args)
+		}
+		if called != 1 {
+			t.Errorf("Parse(%q) called Usage %d times, want 1", args, called)
+		}
+		if want := "  -timeout wait"; !strings.Contains(buf.String(), want) {
+			t.Errorf("Parse(%q) output %q does not contain %q", args, buf.String(), want)
+		}
+	}
+}
+
+func TestPrintDefaults(t *testing.T) {
+	f := newTestSet()
+	f.Duration("timeout", 30*time.Second, "how long to wait")
+	f.Bool("v", false, "verbose")
+	f.String("out", "", "write output to `FILE`")
+	f.Int("n", 0, "count")
+	var buf bytes.Buffer
+	f.PrintDefaults(&buf)
+	want := "" +
+		"  -n int               count\n" +
+		"  -out FILE            write output to FILE\n" +
+		"  -timeout duration    how long to wait (default 30s)\n" +
+		"  -v                   verbose\n"
+	if buf.String() != want {
+		t.Errorf("PrintDefaults wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	f := newTestSet()
+	n := f.Int("n", 1, "")
+	s := f.String("s", "a", "")
+	set := f.Int("set", 1, "")
+	if err := f.Set("set", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.ApplyDefaults(map[string]string{"n": "10", "s": "b", "set": "20"}); err != nil {
+		t.Fatal(err)
+	}
+	if *n != 10 || *s != "b" || *set != 2 {
+		t.Errorf("n=%d s=%q set=%d, want 10, b, 2", *n, *s, *set)
+	}
+	if got := f.Lookup("n").DefValue; got != "10" {
+		t.Errorf("DefValue of n = %q, want 10", got)
+	}
+	if got := f.Lookup("set").DefValue; got != "1" {
+		t.Errorf("DefValue of set = %q, want 1", got)
+	}
+	if f.Changed("n") {
+		t.Error("ApplyDefaults marked n as set")
+	}
+
+	if err := f.ApplyDefaults(map[string]string{"unknown": "x", "n": "y"}); err == nil {
+		t.Error("unknown name and bad value accepted")
+	}
+	if *n != 10 {
+		t.Errorf("n = %d after a bad default, want 10", *n)
+	}
+	f.AllowUnknown = true
+	if err := f.ApplyDefaults(map[string]string{"unknown": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.GetString("unknown"); err != nil || got != "x" {
+		t.Errorf("GetString(unknown) = %q, %v; want x", got, err)
+	}
+}
+
+func TestOnChangeUsesSet(t *testing.T) {
+	f := newTestSet()
+	f.Int("a", 1, "")
+	f.Int("b", 1, "")
+	var seen []string
+	f.OnChange("a", func(old, new Value) {
+		seen = append(seen, fmt.Sprintf("%s changed=%v has=%v source=%s", new, f.Changed("a"), f.Has("b"), f.Source("a")))
+		// A callback may also change the set.
+		f.Set("b", new.String())
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Set("a", "2")
+		f.Parse([]string{"-a=3"})
+		f.LoadBytes([]byte("a=4\n"))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change function calling back into the set deadlocked")
+	}
+	want := []string{
+		"2 changed=true has=true source=set",
+		"3 changed=true has=true source=command-line",
+		"4 changed=true has=true source=reader",
+	}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("change functions saw %q, want %q", seen, want)
+	}
+	if got, _ := f.GetInt("b"); got != 4 {
+		t.Errorf("b = %d, want 4", got)
+	}
+}
+
+func TestUsageName(t *testing.T) {
+	tests := []struct {
+		define func(f *ConfigSet)
+		name   string
+		usage  string
+	}{
+		{func(f *ConfigSet) { f.String("v", "", "write output to `FILE`") }, "FILE", "write output to FILE"},
+		{func(f *ConfigSet) { f.String("v", "", "a `NAME` and `OTHER`") }, "NAME", "a NAME and `OTHER`"},
+		{func(f *ConfigSet) { f.String("v", "", "a string") }, "string", "a string"},
+		{func(f *ConfigSet) { f.Int("v", 0, "a number") }, "int", "a number"},
+		{func(f *ConfigSet) { f.Duration("v", 0, "a wait") }, "duration", "a wait"},
+		{func(f *ConfigSet) { f.Bool("v", false, "a switch") }, "", "a switch"},
+		{func(f *ConfigSet) { f.Bool("v", false, "use `MODE`") }, "MODE", "use MODE"},
+		{func(f *ConfigSet) { f.String("v", "", "unclosed `quote") }, "string", "unclosed `quote"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		tt.define(f)
+		name, usage := f.Lookup("v").UsageName()
+		if name != tt.name || usage != tt.usage {
+			t.Errorf("UsageName() = %q, %q; want %q, %q", name, usage, tt.name, tt.usage)
+		}
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	f := newTestSet()
+	n := f.Int("n", 1, "")
+	if err := f.Set("n", "5"); err != nil {
+		t.Fatal(err)
+	}
+	f.Freeze()
+	if !f.Frozen() {
+		t.Fatal("Frozen() = false after Freeze")
+	}
+	mutators := map[string]func() error{
+		"Set":             func() error { return f.Set("n", "6") },
+		"Parse":           func() error { return f.Parse([]string{"-n=6"}) },
+		"LoadBytes":       func() error { return f.LoadBytes([]byte("n=6\n")) },
+		"TryVar":          func() error { return f.TryVar(newIntValue(0, new(int)), "m", "") },
+		"ResetToDefaults": f.ResetToDefaults,
+		"Unset":           func() error { return f.Unset("n") },
+		"section Unset":   func() error { return f.Section("db").Unset("port") },
+	}
+	for name, mutate := range mutators {
+		if err := mutate(); !errors.Is(err, ErrFrozen) {
+			t.Errorf("%s on a frozen set: error = %v, want ErrFrozen", name, err)
+		}
+	}
+	if *n != 5 || !f.Has("n") {
+		t.Errorf("frozen set changed: n = %d, Has(n) = %v", *n, f.Has("n"))
+	}
+	if got, err := f.GetInt("n"); err != nil || got != 5 {
+		t.Errorf("GetInt(n) = %d, %v; want 5", got, err)
+	}
+
+	f.Unfreeze()
+	if err := f.Set("n", "6"); err != nil || *n != 6 {
+		t.Errorf("Set after Unfreeze: n = %d, %v; want 6", *n, err)
+	}
+}
+
+func TestLoadRepeatedKeys(t *testing.T) {
+	f := newTestSet()
+	var log bytes.Buffer
+	f.SetLogger(&log)
+	tags := f.StringSlice("tag", []string{"default"}, "")
+	ports := f.IntSlice("port", nil, "")
+	labels := f.StringMap("label", nil, "")
+	n := f.Int("n", 0, "")
+	file := []byte("tag=a\ntag=b,c\nport=80\nport=443\nlabel=x=1\nlabel=y=2\nn=1\nn=2\n")
+	for i := 0; i < 3; i++ {
+		if err := f.LoadBytes(file); err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"a", "b", "c"}; !reflect.DeepEqual(*tags, want) {
+			t.Errorf("load %d: tag = %q, want %q", i, *tags, want)
+		}
+		if want := []int{80, 443}; !reflect.DeepEqual(*ports, want) {
+			t.Errorf("load %d: port = %v, want %v", i, *ports, want)
+		}
+		if want := map[string]string{"x": "1", "y": "2"}; !reflect.DeepEqual(*labels, want) {
+			t.Errorf("load %d: label = %v, want %v", i, *labels, want)
+		}
+		if *n != 2 {
+			t.Errorf("load %d: n = %d, want 2", i, *n)
+		}
+	}
+	if !strings.Contains(log.String(), "config n is set more than once") {
+		t.Errorf("no warning for repeated scalar; log:\n%s", log.String())
+	}
+	if strings.Contains(log.String(), "config tag is set more than once") {
+		t.Errorf("warning for repeated list; log:\n%s", log.String())
+	}
+
+	if err := f.LoadBytes([]byte("tag=z\n")); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"z"}; !reflect.DeepEqual(*tags, want) {
+		t.Errorf("tag = %q after loading one line, want %q", *tags, want)
+	}
+}
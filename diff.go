@@ -0,0 +1,76 @@
+package goflagconfig
+
+import "sort"
+
+// A DiffKind says how a config differs between two config sets.
+type DiffKind int
+
+// These constants describe how a config in the other set passed to Diff
+// differs from the config in the receiver.
+const (
+	ConfigAdded   DiffKind = iota // The config is only defined in the other set.
+	ConfigRemoved                 // The config is only defined in the receiver.
+	ConfigChanged                 // The config has a different value in the other set.
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case ConfigAdded:
+		return "added"
+	case ConfigRemoved:
+		return "removed"
+	case ConfigChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// A ConfigDiff describes a config that differs between two config sets.
+type ConfigDiff struct {
+	Name string   // name of the config
+	Old  string   // value in the receiver of Diff, or "" if ConfigAdded
+	New  string   // value in the other set, or "" if ConfigRemoved
+	Kind DiffKind // how the config differs
+}
+
+// values returns the String form of the value of each config in f.
+func (f *ConfigSet) values() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := make(map[string]string, len(f.formal))
+	for name, config := range f.formal {
+		m[name] = config.Value.String()
+	}
+	return m
+}
+
+// Diff compares the configs defined in f with those defined in other by
+// their String forms, and returns the differences in order of name, as
+// changes that would turn f into other. Configs that are the same in both
+// sets are left out.
+func (f *ConfigSet) Diff(other *ConfigSet) []ConfigDiff {
+	f.checkNotSection("Diff")
+	before, after := f.values(), other.values()
+	var diffs []ConfigDiff
+	for name, o := range before {
+		n, ok := after[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, ConfigDiff{name, o, "", ConfigRemoved})
+		case n != o:
+			diffs = append(diffs, ConfigDiff{name, o, n, ConfigChanged})
+		}
+	}
+	for name, n := range after {
+		if _, ok := before[name]; !ok {
+			diffs = append(diffs, ConfigDiff{name, "", n, ConfigAdded})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
+
+// Diff compares the command-line configs with those defined in other.
+func Diff(other *ConfigSet) []ConfigDiff {
+	return Configuration.Diff(other)
+}
@@ -0,0 +1,53 @@
+package goflagconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	running := newTestSet()
+	running.Int("port", 80, "")
+	running.String("host", "a", "")
+	running.String("old", "x", "")
+	running.String("same", "s", "")
+
+	candidate := newTestSet()
+	candidate.Int("port", 80, "")
+	candidate.String("host", "b", "")
+	candidate.String("new", "y", "")
+	candidate.String("same", "s", "")
+	candidate.Set("port", "8080")
+
+	tests := []struct {
+		f, other *ConfigSet
+		want     []ConfigDiff
+	}{
+		{running, candidate, []ConfigDiff{
+			{"host", "a", "b", ConfigChanged},
+			{"new", "", "y", ConfigAdded},
+			{"old", "x", "", ConfigRemoved},
+			{"port", "80", "8080", ConfigChanged},
+		}},
+		{candidate, running, []ConfigDiff{
+			{"host", "b", "a", ConfigChanged},
+			{"new", "y", "", ConfigRemoved},
+			{"old", "", "x", ConfigAdded},
+			{"port", "8080", "80", ConfigChanged},
+		}},
+		{running, running, nil},
+		{running, running.Clone(), nil},
+	}
+	for i, tt := range tests {
+		if got := tt.f.Diff(tt.other); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: Diff = %v, want %v", i, got, tt.want)
+		}
+	}
+
+	kinds := map[DiffKind]string{ConfigAdded: "added", ConfigRemoved: "removed", ConfigChanged: "changed", 7: "unknown"}
+	for k, want := range kinds {
+		if got := k.String(); got != want {
+			t.Errorf("DiffKind(%d).String() = %q, want %q", int(k), got, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package goflagconfig
+
+import (
+	"flag"
+	"reflect"
+)
+
+// A flagValue adapts a flag.Value from the standard flag package to Value.
+type flagValue struct {
+	flag.Value
+}
+
+// Get returns the value of the flag if it implements flag.Getter, as all
+// the flag package's own values do, and its String form otherwise.
+func (v *flagValue) Get() interface{} {
+	if g, ok := v.Value.(flag.Getter); ok {
+		return g.Get()
+	}
+	return v.Value.String()
+}
+
+func (v *flagValue) IsBoolConfig() bool {
+	b, ok := v.Value.(interface {
+		IsBoolFlag() bool
+	})
+	return ok && b.IsBoolFlag()
+}
+
+func (v *flagValue) typeName() string {
+	name, _ := flag.UnquoteUsage(&flag.Flag{Value: v.Value})
+	return name
+}
+
+func (v *flagValue) newValue() Value {
+	t := reflect.TypeOf(v.Value)
+	if t.Kind() != reflect.Ptr {
+		return nil
+	}
+	fv, ok := reflect.New(t.Elem()).Interface().(flag.Value)
+	if !ok {
+		return nil
+	}
+	return &flagValue{fv}
+}
+
+// CopyFromFlagSet defines a config for each flag defined in fs, with the
+// same name, usage and default value. The config is backed by the flag's
+// own Value, so setting the config sets the flag and the variable it
+// points to. A flag that cannot be defined, such as one with the same name
+// as a config already defined in f, is handled as by Var.
+func (f *ConfigSet) CopyFromFlagSet(fs *flag.FlagSet) {
+	f.checkNotSection("CopyFromFlagSet")
+	fs.VisitAll(func(fl *flag.Flag) {
+		v := &flagValue{fl.Value}
+		f.Var(v, fl.Name, fl.Usage)
+		if config := f.Lookup(fl.Name); config != nil && config.Value == Value(v) {
+			config.DefValue = fl.DefValue
+		}
+	})
+}
+
+// CopyFromFlagSet defines a command-line config for each flag defined in fs.
+func CopyFromFlagSet(fs *flag.FlagSet) {
+	Configuration.CopyFromFlagSet(fs)
+}
+
+// A configFlag adapts a config of a ConfigSet to flag.Value. Setting it
+// sets the config through the ConfigSet, so validators and OnChange
+// functions apply.
+type configFlag struct {
+	f    *ConfigSet
+	name string
+}
+
+func (c *configFlag) String() string {
+	if c.f == nil {
+		return ""
+	}
+	return c.f.Lookup(c.name).Value.String()
+}
+
+func (c *configFlag) Set(s string) error { return c.f.Set(c.name, s) }
+
+func (c *configFlag) Get() interface{} { return getValue(c.f.Lookup(c.name).Value) }
+
+func (c *configFlag) IsBoolFlag() bool {
+	b, ok := c.f.Lookup(c.name).Value.(boolConfig)
+	return ok && b.IsBoolConfig()
+}
+
+// ToFlagSet returns a new flag.FlagSet with the given name and error
+// handling policy that defines a flag for each config in f. Parsing the
+// flag set sets the configs in f.
+func (f *ConfigSet) ToFlagSet(name string, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	f.checkNotSection("ToFlagSet")
+	fs := flag.NewFlagSet(name, errorHandling)
+	f.VisitAll(func(config *Config) {
+		fs.Var(&configFlag{f, config.Name}, config.Name, config.Usage)
+		fs.Lookup(config.Name).DefValue = config.DefValue
+	})
+	return fs
+}
+
+// ToFlagSet returns a new flag.FlagSet defining a flag for each
+// command-line config.
+func ToFlagSet(name string, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	return Configuration.ToFlagSet(name, errorHandling)
+}
@@ -0,0 +1,99 @@
+package goflagconfig
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestCopyFromFlagSet(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	n := fs.Int("n", 3, "a number")
+	b := fs.Bool("b", false, "a bool")
+
+	f := newTestSet()
+	f.CopyFromFlagSet(fs)
+	if err := f.Set("n", "42"); err != nil {
+		t.Fatal(err)
+	}
+	if *n != 42 || fs.Lookup("n").Value.String() != "42" {
+		t.Errorf("n = %d, flag = %s; want 42", *n, fs.Lookup("n").Value)
+	}
+	if got := f.Lookup("n").DefValue; got != "3" {
+		t.Errorf("DefValue = %q, want 3", got)
+	}
+	if err := f.Parse([]string{"-b"}); err != nil || !*b {
+		t.Errorf("Parse(-b): b = %v, %v; want true", *b, err)
+	}
+	if got := f.Lookup("n").Value.(Getter).Get(); got != 42 {
+		t.Errorf("Get() = %v, want 42", got)
+	}
+}
+
+func TestCopyFromFlagSetUndefinable(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("n", 3, "")
+	fs.Int("m", 4, "")
+
+	f := newTestSet()
+	f.String("n", "x", "")
+	f.CopyFromFlagSet(fs)
+	if got := f.Lookup("n").Value.String(); got != "x" {
+		t.Errorf("existing config n = %q, want x", got)
+	}
+	if f.Lookup("m") == nil {
+		t.Error("m was not defined")
+	}
+
+	frozen := newTestSet()
+	frozen.Freeze()
+	frozen.CopyFromFlagSet(fs)
+	if frozen.Lookup("n") != nil {
+		t.Error("flag defined in a frozen set")
+	}
+}
+
+func TestToFlagSet(t *testing.T) {
+	tests := []struct {
+		args []string
+		n    int
+		b    bool
+		ok   bool
+	}{
+		{nil, 3, false, true},
+		{[]string{"-n=42", "-b"}, 42, true, true},
+		{[]string{"-n", "7"}, 7, false, true},
+		{[]string{"-n=x"}, 3, false, false},
+		{[]string{"-n=-1"}, 3, false, false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		n := f.Int("n", 3, "a number")
+		b := f.Bool("b", false, "a bool")
+		f.SetValidator("n", func(v Value) error {
+			if v.(Getter).Get().(int) < 0 {
+				return errors.New("negative")
+			}
+			return nil
+		})
+		fs := f.ToFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		err := fs.Parse(tt.args)
+		if (err == nil) != tt.ok {
+			t.Errorf("Parse(%q) error = %v, want ok=%v", tt.args, err, tt.ok)
+		}
+		if *n != tt.n || *b != tt.b {
+			t.Errorf("Parse(%q): n, b = %d, %v, want %d, %v", tt.args, *n, *b, tt.n, tt.b)
+		}
+		if got := fs.Lookup("n").DefValue; got != "3" {
+			t.Errorf("DefValue = %q, want 3", got)
+		}
+		if got := fs.Lookup("n").Value.(flag.Getter).Get(); got != tt.n {
+			t.Errorf("Get() = %v, want %d", got, tt.n)
+		}
+		if tt.ok && len(tt.args) > 0 && !f.Changed("n") {
+			t.Errorf("Parse(%q) did not mark n changed", tt.args)
+		}
+	}
+}
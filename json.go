@@ -0,0 +1,125 @@
+package goflagconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonValue returns the value of config as it should be marshaled to JSON.
+// Booleans, numbers and strings keep their type; any other value is
+// represented by its String form so that it can be read back by Set.
+func jsonValue(config *Config) interface{} {
+	switch v := getValue(config.Value).(type) {
+	case bool, int, int32, int64, uint, uint32, uint64, float32, float64, string:
+		return v
+	}
+	return config.Value.String()
+}
+
+// jsonInsert stores v in m under the config name, in an object nested for
+// each section of the name. If a config is already stored where an object
+// would go, the rest of the name is used as a key in its place.
+func jsonInsert(m map[string]interface{}, name string, v interface{}) {
+	parts := strings.Split(name, ".")
+	for i, part := range parts[:len(parts)-1] {
+		sub, ok := m[part].(map[string]interface{})
+		if !ok {
+			if _, exists := m[part]; exists {
+				m[strings.Join(parts[i:], ".")] = v
+				return
+			}
+			sub = make(map[string]interface{})
+			m[part] = sub
+		}
+		m = sub
+	}
+	m[parts[len(parts)-1]] = v
+}
+
+// jsonFlatten adds the values in m to flat keyed by config name, joining
+// the keys of nested objects with dots, as sections are named.
+func jsonFlatten(flat, m map[string]interface{}, prefix string) {
+	for key, v := range m {
+		if sub, ok := v.(map[string]interface{}); ok {
+			jsonFlatten(flat, sub, prefix+key+".")
+			continue
+		}
+		flat[prefix+key] = v
+	}
+}
+
+// SaveJSON writes the configs to w as a JSON object keyed by config name,
+// with the configs in each section in a nested object keyed by the section
+// name. If RedactSecrets is set, secret configs are written redacted.
+func (f *ConfigSet) SaveJSON(w io.Writer) error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := make(map[string]interface{})
+	for _, config := range f.saveConfigs(f.RedactSecrets) {
+		jsonInsert(m, config.Name, jsonValue(config))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	return nil
+}
+
+// LoadJSON reads a JSON object from r and sets each config named by one of
+// its keys. The keys of a nested object name the configs in a section, so
+// {"db": {"host": "x"}} sets db.host, as does {"db.host": "x"}. Values
+// must be JSON booleans, numbers or strings; they are passed to Set in
+// their textual form. Values that fail to set are handled according to the
+// error handling policy.
+func (f *ConfigSet) LoadJSON(r io.Reader) error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	f.mu.Lock()
+	defer f.unlock()
+	var nested map[string]interface{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&nested); err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	m := make(map[string]interface{})
+	jsonFlatten(m, nested, "")
+
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		var val string
+		switch v := m[name].(type) {
+		case string:
+			val = v
+		case json.Number:
+			val = v.String()
+		case bool:
+			val = strconv.FormatBool(v)
+		default:
+			err := f.failf("config %s: unsupported JSON value %v", name, v)
+			errs = append(errs, f.handleError(err))
+			continue
+		}
+		if err := f.setFrom(name, val, "json"); err != nil {
+			err = f.failf("config %s: %w", name, err)
+			errs = append(errs, f.handleError(err))
+		}
+	}
+	return errors.Join(errs...)
+}
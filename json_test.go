@@ -0,0 +1,139 @@
+package goflagconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJSONTypes(t *testing.T) {
+	f := newTestSet()
+	f.Bool("b", true, "")
+	f.Int("n", -3, "")
+	f.Uint64("u", 1<<63, "")
+	f.Float64("x", 1.5, "")
+	f.String("s", "text", "")
+	f.Duration("d", time.Second, "")
+	f.StringSlice("list", []string{"a", "b"}, "")
+	var buf bytes.Buffer
+	if err := f.SaveJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	dec.UseNumber()
+	var got map[string]interface{}
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"b":    true,
+		"n":    json.Number("-3"),
+		"u":    json.Number("9223372036854775808"),
+		"x":    json.Number("1.5"),
+		"s":    "text",
+		"d":    "1s",
+		"list": "a,b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SaveJSON wrote %s", buf.String())
+	}
+
+	g := newTestSet()
+	g.Bool("b", false, "")
+	g.Int("n", 0, "")
+	g.Uint64("u", 0, "")
+	g.Float64("x", 0, "")
+	g.String("s", "", "")
+	g.Duration("d", 0, "")
+	g.StringSlice("list", nil, "")
+	if err := g.LoadJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if diffs := f.Diff(g); len(diffs) != 0 {
+		t.Errorf("round trip differs: %v", diffs)
+	}
+}
+
+func TestLoadJSONErrors(t *testing.T) {
+	tests := []string{
+		`{"n": "x"}`,
+		`{"n": [1, 2]}`,
+		`{"n": null}`,
+		`{"unknown": 1}`,
+		`not json`,
+	}
+	for _, in := range tests {
+		f := newTestSet()
+		f.Int("n", 0, "")
+		if err := f.LoadJSON(bytes.NewBufferString(in)); err == nil {
+			t.Errorf("LoadJSON(%s) succeeded", in)
+		}
+	}
+}
+
+func TestJSONSections(t *testing.T) {
+	f := newTestSet()
+	defineSections(f)
+	settings := map[string]string{
+		"name":          "svc",
+		"database.host": "db.example.com",
+		"database.port": "6543",
+		"cache.enabled": "true",
+		"cache.ttl":     "5s",
+	}
+	if err := f.SetBatch(settings); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := f.SaveJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name": "svc",
+		"database": map[string]interface{}{
+			"host": "db.example.com",
+			"port": 6543.0,
+		},
+		"cache": map[string]interface{}{
+			"enabled": true,
+			"ttl":     "5s",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SaveJSON wrote %s", buf.String())
+	}
+
+	g := newTestSet()
+	defineSections(g)
+	if err := g.LoadJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if diffs := f.Diff(g); len(diffs) != 0 {
+		t.Errorf("round trip differs: %v", diffs)
+	}
+	if got := g.Source("database.port"); got != "json" {
+		t.Errorf(`Source("database.port") = %q, want "json"`, got)
+	}
+}
+
+func TestLoadJSONFlatKeys(t *testing.T) {
+	f := newTestSet()
+	defineSections(f)
+	in := `{"database.host": "flat", "cache": {"ttl": "1s"}}`
+	if err := f.LoadJSON(bytes.NewBufferString(in)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.GetString("database.host"); got != "flat" {
+		t.Errorf("database.host = %q, want flat", got)
+	}
+	if got, _ := f.GetDuration("cache.ttl"); got != time.Second {
+		t.Errorf("cache.ttl = %v, want 1s", got)
+	}
+}
@@ -0,0 +1,196 @@
+package goflagconfig
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// copyGot returns a copy of v, a value returned by a Value's Get method,
+// that shares no slice or map with it.
+func copyGot(v interface{}) interface{} {
+	if n, ok := v.(net.IPNet); ok {
+		return net.IPNet{
+			IP:   copyGot(n.IP).(net.IP),
+			Mask: copyGot(n.Mask).(net.IPMask),
+		}
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		reflect.Copy(c, rv)
+		return c.Interface()
+	case reflect.Map:
+		if rv.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		for _, k := range rv.MapKeys() {
+			c.SetMapIndex(k, rv.MapIndex(k))
+		}
+		return c.Interface()
+	}
+	return v
+}
+
+// Snapshot returns the current value of each config, as returned by its
+// Value's Get method, keyed by config name. Slices and maps are copied, so
+// changing them does not change the configs. If RedactSecrets is set, the
+// values of secret configs are replaced by a placeholder string.
+func (f *ConfigSet) Snapshot() map[string]interface{} {
+	if f.owner != nil {
+		m := make(map[string]interface{})
+		for name, v := range f.owner.Snapshot() {
+			if strings.HasPrefix(name, f.prefix) {
+				m[name[len(f.prefix):]] = v
+			}
+		}
+		return m
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := make(map[string]interface{}, len(f.formal))
+	f.VisitAll(func(config *Config) {
+		if f.RedactSecrets {
+			config = f.redacted(config)
+		}
+		m[config.Name] = copyGot(getValue(config.Value))
+	})
+	return m
+}
+
+// Snapshot returns the current value of each command-line config.
+func Snapshot() map[string]interface{} {
+	return Configuration.Snapshot()
+}
+
+// GetAll returns the String form of the value of each config, keyed by
+// config name, in the form Set accepts, so that the values can be edited
+// and set again. If RedactSecrets is set, secret configs are redacted.
+func (f *ConfigSet) GetAll() map[string]string {
+	if f.owner != nil {
+		m := make(map[string]string)
+		for name, value := range f.owner.GetAll() {
+			if strings.HasPrefix(name, f.prefix) {
+				m[name[len(f.prefix):]] = value
+			}
+		}
+		return m
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := make(map[string]string, len(f.formal))
+	f.VisitAll(func(config *Config) {
+		if f.RedactSecrets {
+			config = f.redacted(config)
+		}
+		m[config.Name] = config.Value.String()
+	})
+	return m
+}
+
+// GetAll returns the String form of the value of each command-line config.
+func GetAll() map[string]string {
+	return Configuration.GetAll()
+}
+
+// formatGot returns the text form of v, a value of the kind returned by the
+// Get method of value, as value itself would write it. A string is taken to
+// be a text form already. It is an error if v is of another type.
+func formatGot(value Value, v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	want := getValue(value)
+	if reflect.TypeOf(v) != reflect.TypeOf(want) {
+		return "", fmt.Errorf("cannot use value of type %T as %T", v, want)
+	}
+	switch x := v.(type) {
+	case []string:
+		return newStringSliceValue(x, new([]string)).String(), nil
+	case []int:
+		return newIntSliceValue(x, new([]int)).String(), nil
+	case []time.Duration:
+		return newDurationSliceValue(x, new([]time.Duration)).String(), nil
+	case map[string]string:
+		return newStringMapValue(x, new(map[string]string)).String(), nil
+	case net.IPNet:
+		return newIPNetValue(x, new(net.IPNet)).String(), nil
+	case time.Time:
+		if t, ok := value.(*timeValue); ok {
+			return x.Format(t.layout), nil
+		}
+		return x.Format(time.RFC3339Nano), nil
+	case []byte:
+		if b, ok := value.(*encodedBytesValue); ok {
+			return newEncodedBytesValue(x, new([]byte), 0, b.base64).String(), nil
+		}
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return "", nil
+	}
+	switch x := v.(type) {
+	case encoding.TextMarshaler:
+		b, err := x.MarshalText()
+		return string(b), err
+	case fmt.Stringer:
+		return x.String(), nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+// ApplySnapshot sets each config named in m to its value, as returned by
+// Snapshot, so that a snapshot taken earlier, or decoded from any format,
+// can be restored. A value may also be given as a string in the form the
+// config accepts. A list or map value replaces the config's value rather
+// than adding to it. Names that are not defined are handled as by Set, so
+// they are an error unless AllowUnknown is set. ApplySnapshot sets configs
+// in order of name and returns the errors from all the values that could
+// not be set.
+func (f *ConfigSet) ApplySnapshot(m map[string]interface{}) error {
+	if f.owner != nil {
+		prefixed := make(map[string]interface{}, len(m))
+		for name, v := range m {
+			prefixed[f.prefix+name] = v
+		}
+		return f.owner.ApplySnapshot(prefixed)
+	}
+	f.mu.Lock()
+	defer f.unlock()
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		val := fmt.Sprint(m[name])
+		var err error
+		if config, ok := f.formal[f.canonical(name)]; ok {
+			val, err = formatGot(config.Value, m[name])
+		}
+		if err == nil {
+			err = f.set(name, val, setReplace)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config %s: %w", name, err))
+			continue
+		}
+		f.setSource(name, "set")
+	}
+	return errors.Join(errs...)
+}
+
+// ApplySnapshot sets each command-line config named in m to its value.
+func ApplySnapshot(m map[string]interface{}) error {
+	return Configuration.ApplySnapshot(m)
+}
@@ -0,0 +1,179 @@
+package goflagconfig
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	f := newTestSet()
+	f.Int("n", 7, "")
+	f.String("s", "str", "")
+	f.Duration("d", time.Minute, "")
+	list := f.StringSlice("list", []string{"a", "b"}, "")
+	m := f.StringMap("map", map[string]string{"k": "v"}, "")
+	ipnet := f.IPNet("net", net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)}, "")
+	f.String("secret", "hunter2", "")
+	f.SetSecret("secret")
+
+	snap := f.Snapshot()
+	tests := []struct {
+		name string
+		want interface{}
+	}{
+		{"n", 7},
+		{"s", "str"},
+		{"d", time.Minute},
+		{"list", []string{"a", "b"}},
+		{"map", map[string]string{"k": "v"}},
+		{"net", *ipnet},
+		{"secret", "hunter2"},
+	}
+	for _, tt := range tests {
+		if got := snap[tt.name]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Snapshot()[%q] = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+	if len(snap) != len(tests) {
+		t.Errorf("Snapshot() has %d entries, want %d", len(snap), len(tests))
+	}
+
+	snap["list"].([]string)[0] = "changed"
+	snap["map"].(map[string]string)["k"] = "changed"
+	snap["net"].(net.IPNet).IP[0] = 99
+	if (*list)[0] != "a" || (*m)["k"] != "v" || ipnet.IP[0] != 10 {
+		t.Errorf("changing the snapshot changed the configs: %q %q %v", *list, *m, *ipnet)
+	}
+
+	f.RedactSecrets = true
+	if got := f.Snapshot()["secret"]; got != redactedValue {
+		t.Errorf("with RedactSecrets, Snapshot()[secret] = %#v, want %q", got, redactedValue)
+	}
+}
+
+func TestApplySnapshot(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+		ok    bool
+	}{
+		{"n", 9, "9", true},
+		{"n", "10", "10", true},
+		{"n", int64(9), "7", false},
+		{"n", "x", "7", false},
+		{"d", 90 * time.Second, "1m30s", true},
+		{"list", []string{"x", "y"}, "x,y", true},
+		{"list", "z", "z", true},
+		{"map", map[string]string{"a": "1", "b": "2"}, "a=1,b=2", true},
+		{"when", time.Date(2021, 6, 7, 0, 0, 0, 0, time.UTC), "2021-06-07", true},
+		{"net", net.IPNet{IP: net.IPv4(192, 168, 0, 0).To4(), Mask: net.CIDRMask(16, 32)}, "192.168.0.0/16", true},
+		{"hex", []byte{1, 2, 0xab}, "0102ab", true},
+		{"b64", []byte("hi!"), "aGkh", true},
+		{"hex", "ff00", "ff00", true},
+		{"missing", 1, "", false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.Int("n", 7, "")
+		f.Duration("d", time.Minute, "")
+		f.StringSlice("list", []string{"a"}, "")
+		f.StringMap("map", map[string]string{"old": "v"}, "")
+		f.Time("when", time.Time{}, "2006-01-02", "")
+		f.IPNet("net", net.IPNet{}, "")
+		f.BytesHex("hex", 0, nil, "")
+		f.BytesBase64("b64", 0, nil, "")
+		err := f.ApplySnapshot(map[string]interface{}{tt.name: tt.value})
+		if (err == nil) != tt.ok {
+			t.Errorf("ApplySnapshot(%s: %#v) error = %v, want ok=%v", tt.name, tt.value, err, tt.ok)
+		}
+		if config := f.Lookup(tt.name); config != nil && config.Value.String() != tt.want {
+			t.Errorf("ApplySnapshot(%s: %#v): %s = %q, want %q", tt.name, tt.value, tt.name, config.Value, tt.want)
+		}
+		if tt.ok && f.Source(tt.name) != "set" {
+			t.Errorf("ApplySnapshot(%s: %#v): Source = %q, want %q", tt.name, tt.value, f.Source(tt.name), "set")
+		}
+	}
+
+	// A snapshot restores every kind of value.
+	f := newTestSet()
+	f.Int("n", 7, "")
+	f.BytesHex("hex", 0, []byte{0xde, 0xad}, "")
+	f.BytesBase64("b64", 0, []byte("key"), "")
+	f.Time("when", time.Date(2021, 6, 7, 0, 0, 0, 0, time.UTC), "2006-01-02", "")
+	f.IPNet("net", net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)}, "")
+	list := f.StringSlice("list", []string{"a"}, "")
+	f.Set("list", "b")
+	before := f.GetAll()
+	if err := f.ApplySnapshot(f.Snapshot()); err != nil {
+		t.Fatalf("ApplySnapshot(Snapshot()): %v", err)
+	}
+	if after := f.GetAll(); !reflect.DeepEqual(after, before) {
+		t.Errorf("ApplySnapshot(Snapshot()) changed the values from %v to %v", before, after)
+	}
+
+	snap := f.Snapshot()
+	g := newTestSet()
+	n := g.Int("n", 0, "")
+	g.BytesHex("hex", 0, nil, "")
+	g.BytesBase64("b64", 0, nil, "")
+	g.Time("when", time.Time{}, "2006-01-02", "")
+	g.IPNet("net", net.IPNet{}, "")
+	glist := g.StringSlice("list", []string{"z"}, "")
+	g.Set("list", "y")
+	if err := g.ApplySnapshot(snap); err != nil {
+		t.Fatal(err)
+	}
+	if *n != 7 || !reflect.DeepEqual(*glist, *list) {
+		t.Errorf("restoring a snapshot gave n=%d list=%q, want 7 %q", *n, *glist, *list)
+	}
+
+	g.AllowUnknown = true
+	if err := g.ApplySnapshot(map[string]interface{}{"extra": 5}); err != nil {
+		t.Errorf("ApplySnapshot of an unknown name with AllowUnknown: %v", err)
+	}
+	if got, _ := g.GetString("extra"); got != "5" {
+		t.Errorf("extra = %q, want %q", got, "5")
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	f := newTestSet()
+	f.Int("n", 7, "")
+	f.Bool("b", false, "")
+	f.Duration("d", time.Minute, "")
+	f.StringSlice("list", []string{"a", "b"}, "")
+	f.Section("db").String("host", "localhost", "")
+	f.String("secret", "hunter2", "")
+	f.SetSecret("secret")
+	f.Set("b", "true")
+	want := map[string]string{
+		"n":       "7",
+		"b":       "true",
+		"d":       "1m0s",
+		"list":    "a,b",
+		"db.host": "localhost",
+		"secret":  "hunter2",
+	}
+	got := f.GetAll()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAll() = %v, want %v", got, want)
+	}
+
+	// The values can be set again unchanged.
+	for name, value := range got {
+		if err := f.Set(name, value); err != nil {
+			t.Errorf("Set(%q, %q): %v", name, value, err)
+		}
+	}
+	if again := f.GetAll(); !reflect.DeepEqual(again, want) {
+		t.Errorf("GetAll() after setting its values = %v, want %v", again, want)
+	}
+
+	f.RedactSecrets = true
+	if got := f.GetAll()["secret"]; got != redactedValue {
+		t.Errorf("GetAll()[secret] under RedactSecrets = %q, want %q", got, redactedValue)
+	}
+}
@@ -0,0 +1,266 @@
+package goflagconfig
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tomlQuote returns s as a TOML basic string.
+func tomlQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlKey returns key as it should appear in a TOML file: bare if it is
+// made of letters, digits, underscores and dashes, and quoted otherwise.
+func tomlKey(key string) string {
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-') {
+			return tomlQuote(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
+
+// tomlValue returns the value of config as it should appear in a TOML
+// file. Booleans and numbers are written as TOML booleans, integers and
+// floats; any other value is written as a string of its String form.
+func tomlValue(config *Config) string {
+	switch v := getValue(config.Value).(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case int, int32, int64, uint, uint32, uint64:
+		return fmt.Sprint(v)
+	case float32:
+		return tomlFloat(float64(v), 32)
+	case float64:
+		return tomlFloat(v, 64)
+	}
+	return tomlQuote(config.Value.String())
+}
+
+// tomlFloat returns v as a TOML float, which always has a decimal point or
+// an exponent.
+func tomlFloat(v float64, bitSize int) string {
+	switch {
+	case math.IsNaN(v):
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(v, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// SaveTOML writes the configs to w as TOML: those without a section as
+// key/value pairs, followed by a table for each section. If RedactSecrets
+// is set, secret configs are written redacted.
+func (f *ConfigSet) SaveTOML(w io.Writer) error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	sections, grouped := groupSections(f.saveConfigs(f.RedactSecrets))
+	writeList := func(configs []*Config) {
+		for _, config := range configs {
+			_, key := splitSection(config.Name)
+			fmt.Fprintf(bw, "%s = %s\n", tomlKey(key), tomlValue(config))
+		}
+	}
+	writeList(grouped[""])
+	for i, section := range sections {
+		if i > 0 || len(grouped[""]) > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "[%s]\n", section)
+		writeList(grouped[section])
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	return nil
+}
+
+// parseTOMLString reads the TOML basic or literal string at the start of s
+// and returns its value and the rest of s.
+func parseTOMLString(s string) (string, string, error) {
+	if s[0] == '\'' {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", errors.New("unterminated string")
+		}
+		return s[1 : 1+end], s[2+end:], nil
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			v, err := strconv.Unquote(s[:i+1])
+			if err != nil || !utf8.ValidString(v) {
+				return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+			}
+			return v, s[i+1:], nil
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
+
+// parseTOMLKey reads the bare or quoted key at the start of s and returns
+// it and the rest of s.
+func parseTOMLKey(s string) (string, string, error) {
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		return parseTOMLString(s)
+	}
+	i := strings.IndexAny(s, " \t=")
+	if i < 0 {
+		i = len(s)
+	}
+	if i == 0 {
+		return "", "", errors.New("missing key")
+	}
+	return s[:i], s[i:], nil
+}
+
+// parseTOMLValue returns the text to set a config to for the TOML value at
+// the start of s, which may be followed by a comment.
+func parseTOMLValue(s string) (string, error) {
+	if s == "" {
+		return "", errors.New("missing value")
+	}
+	var val, rest string
+	switch s[0] {
+	case '"', '\'':
+		if strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''") {
+			return "", errors.New("multi-line strings are not supported")
+		}
+		var err error
+		if val, rest, err = parseTOMLString(s); err != nil {
+			return "", err
+		}
+	case '[', '{':
+		return "", errors.New("arrays and inline tables are not supported")
+	default:
+		val, rest = s, ""
+		if i := strings.IndexByte(s, '#'); i > -1 {
+			val, rest = s[:i], s[i:]
+		}
+		val = strings.TrimSpace(val)
+		if val != "true" && val != "false" {
+			// Numbers may have underscores between digits.
+			val = strings.Replace(val, "_", "", -1)
+		}
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+		return "", fmt.Errorf("unexpected text after value: %s", rest)
+	}
+	return val, nil
+}
+
+// LoadTOML reads a TOML document from r and sets each config named by one of
+// its keys, within a table for a config in a section. Only key/value pairs,
+// tables and comments are supported, and values must be strings, integers,
+// floats or booleans; they are passed to Set in their textual form. Lines
+// that fail to parse or set are handled according to the error handling
+// policy.
+func (f *ConfigSet) LoadTOML(r io.Reader) error {
+	if f.owner != nil {
+		return f.errSection()
+	}
+	f.mu.Lock()
+	defer f.unlock()
+	if f.frozen {
+		return f.errFrozen()
+	}
+	var errs []error
+	section := ""
+	lineno := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			if i := strings.IndexByte(line, '#'); i > -1 {
+				line = strings.TrimSpace(line[:i])
+			}
+			if name, ok := parseSection(line); ok && !strings.HasPrefix(line, "[[") {
+				section = name
+				continue
+			}
+		}
+		key, rest, err := parseTOMLKey(line)
+		var val string
+		if err == nil {
+			rest = strings.TrimSpace(rest)
+			if !strings.HasPrefix(rest, "=") {
+				err = errors.New("missing =")
+			}
+		}
+		if err == nil {
+			val, err = parseTOMLValue(strings.TrimSpace(rest[1:]))
+		}
+		if err == nil {
+			if section != "" {
+				key = section + "." + key
+			}
+			err = f.setFrom(key, val, "toml")
+			if err != nil && f.formal[f.canonical(key)] != nil {
+				err = fmt.Errorf("invalid value %q for config -%s: %w", val, key, err)
+			}
+		}
+		if err != nil {
+			err = f.failf("line %d: %s: %w", lineno, line, err)
+			errs = append(errs, f.handleError(err))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	return errors.Join(errs...)
+}
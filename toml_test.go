@@ -0,0 +1,131 @@
+package goflagconfig
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// defineSections defines configs in the sections database and cache.
+func defineSections(f *ConfigSet) {
+	f.String("name", "app", "")
+	db := f.Section("database")
+	db.String("host", "localhost", "")
+	db.Int("port", 5432, "")
+	cache := f.Section("cache")
+	cache.Bool("enabled", false, "")
+	cache.Duration("ttl", time.Minute, "")
+}
+
+func TestSaveTOML(t *testing.T) {
+	f := newTestSet()
+	defineSections(f)
+	f.Float64("ratio", 2, "")
+	f.String("odd key", "say \"hi\"\n", "")
+	f.Set("database.port", "6543")
+	var buf bytes.Buffer
+	if err := f.SaveTOML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `name = "app"
+"odd key" = "say \"hi\"\n"
+ratio = 2.0
+
+[cache]
+enabled = false
+ttl = "1m0s"
+
+[database]
+host = "localhost"
+port = 6543
+`
+	if buf.String() != want {
+		t.Errorf("SaveTOML wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	g := newTestSet()
+	defineSections(g)
+	g.Float64("ratio", 0, "")
+	g.String("odd key", "", "")
+	if err := g.LoadTOML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if diffs := f.Diff(g); diffs != nil {
+		t.Errorf("round trip through TOML differs: %v", diffs)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	tests := []struct {
+		line string
+		name string
+		want string
+	}{
+		{`s = "a \"b\"\tc"`, "s", "a \"b\"\tc"},
+		{`s = 'C:\path' # comment`, "s", `C:\path`},
+		{`"s" = "quoted key"`, "s", "quoted key"},
+		{`n = 1_000_000`, "n", "1000000"},
+		{`n = -5 # comment`, "n", "-5"},
+		{`x = 6.02e23`, "x", "6.02e+23"},
+		{`x = inf`, "x", "+Inf"},
+		{`b = true`, "b", "true"},
+		{"[db]\nport = 1", "db.port", "1"},
+		{"[db] # the database\nport = 2", "db.port", "2"},
+		{"# comment\n\n  s = 'x'  ", "s", "x"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.String("s", "", "")
+		f.Int("n", 0, "")
+		f.Float64("x", 0, "")
+		f.Bool("b", false, "")
+		f.Section("db").Int("port", 0, "")
+		if err := f.LoadTOML(strings.NewReader(tt.line)); err != nil {
+			t.Errorf("LoadTOML(%q): %v", tt.line, err)
+			continue
+		}
+		if got := f.Lookup(tt.name).Value.String(); got != tt.want {
+			t.Errorf("LoadTOML(%q): %s = %q, want %q", tt.line, tt.name, got, tt.want)
+		}
+		if got := f.Source(tt.name); got != "toml" {
+			t.Errorf("LoadTOML(%q): Source(%s) = %q, want %q", tt.line, tt.name, got, "toml")
+		}
+	}
+}
+
+func TestLoadTOMLErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		err  string
+	}{
+		{`s "no equals"`, "missing ="},
+		{`s =`, "missing value"},
+		{`s = "open`, "unterminated string"},
+		{`s = 'open`, "unterminated string"},
+		{`s = "a" b`, "unexpected text after value: b"},
+		{`s = """multi"""`, "multi-line strings are not supported"},
+		{`s = [1, 2]`, "arrays and inline tables are not supported"},
+		{`s = { a = 1 }`, "arrays and inline tables are not supported"},
+		{`n = "x"`, `invalid value "x" for config -n`},
+		{`= 1`, "missing key"},
+		{`missing = 1`, "no such config missing"},
+		{"[[array]]\nn = 1", "line 1"},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.String("s", "", "")
+		f.Int("n", 0, "")
+		err := f.LoadTOML(strings.NewReader(tt.line))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("LoadTOML(%q) error = %v, want %q", tt.line, err, tt.err)
+		}
+	}
+
+	f := newTestSet()
+	x := f.Float64("x", 0, "")
+	if err := f.LoadTOML(strings.NewReader("x = nan")); err != nil || !math.IsNaN(*x) {
+		t.Errorf("LoadTOML(x = nan): x = %v, %v", *x, err)
+	}
+}
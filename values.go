@@ -0,0 +1,1501 @@
+package goflagconfig
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// splitEscaped splits s into at most n parts (all parts if n < 0) at each
+// sep not escaped by a backslash. The escapes are left in place.
+func splitEscaped(s string, sep byte, n int) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s) && n != 1; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+			n--
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescape replaces "\c" by c in s for each c in chars and for backslash
+// itself. Any other backslash is kept as is.
+func unescape(s string, chars string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '\\' || strings.IndexByte(chars, s[i+1]) > -1) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// escape is the inverse of unescape: it puts a backslash before each
+// backslash and each of chars in s.
+func escape(s string, chars string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' || strings.IndexByte(chars, s[i]) > -1 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// splitList splits s at each comma not escaped by a backslash. Within the
+// elements "\," becomes "," and "\\" becomes "\"; any other backslash is
+// kept as is. An empty s yields an empty list.
+func splitList(s string) []string {
+	list := []string{}
+	if s == "" {
+		return list
+	}
+	for _, e := range splitEscaped(s, ',', -1) {
+		list = append(list, unescape(e, ","))
+	}
+	return list
+}
+
+// joinList joins list with commas, escaping it so that splitList returns
+// the original list.
+func joinList(list []string) string {
+	escaped := make([]string, len(list))
+	for i, s := range list {
+		escaped[i] = escape(s, ",")
+	}
+	return strings.Join(escaped, ",")
+}
+
+// -- []string Value
+type stringSliceValue struct {
+	value   *[]string
+	changed bool
+}
+
+func newStringSliceValue(val []string, p *[]string) *stringSliceValue {
+	*p = val
+	return &stringSliceValue{value: p}
+}
+
+// Set replaces the default with the comma-separated list val the first time
+// it is called, and appends to the list on later calls.
+func (s *stringSliceValue) Set(val string) error {
+	v := splitList(val)
+	if !s.changed {
+		*s.value = v
+		s.changed = true
+	} else {
+		*s.value = append(*s.value, v...)
+	}
+	return nil
+}
+
+// Append appends the list val, even if it has not been set before.
+func (s *stringSliceValue) Append(val string) error {
+	changed := s.changed
+	s.changed = true
+	if err := s.Set(val); err != nil {
+		s.changed = changed
+		return err
+	}
+	return nil
+}
+
+func (s *stringSliceValue) Get() interface{} { return *s.value }
+
+func (s *stringSliceValue) newValue() Value { return newStringSliceValue(nil, new([]string)) }
+
+func (s *stringSliceValue) reset(val string) error {
+	s.changed = false
+	err := s.Set(val)
+	s.changed = false
+	return err
+}
+
+func (s *stringSliceValue) saveState() func() {
+	old, changed := append([]string(nil), *s.value...), s.changed
+	return func() { *s.value, s.changed = old, changed }
+}
+
+func (s *stringSliceValue) String() string {
+	if s == nil || s.value == nil {
+		return ""
+	}
+	return joinList(*s.value)
+}
+
+// StringSliceVar defines a []string config with specified name, default value, and usage string.
+// The argument p points to a []string variable in which to store the value of the config.
+// The config accepts a comma-separated list; a comma or backslash within an element
+// is escaped with a backslash. Each occurrence after the first appends to the list.
+func (f *ConfigSet) StringSliceVar(p *[]string, name string, value []string, usage string) {
+	f.Var(newStringSliceValue(value, p), name, usage)
+}
+
+// StringSliceVar defines a []string config with specified name, default value, and usage string.
+// The argument p points to a []string variable in which to store the value of the config.
+// The config accepts a comma-separated list; a comma or backslash within an element
+// is escaped with a backslash. Each occurrence after the first appends to the list.
+func StringSliceVar(p *[]string, name string, value []string, usage string) {
+	Configuration.Var(newStringSliceValue(value, p), name, usage)
+}
+
+// StringSlice defines a []string config with specified name, default value, and usage string.
+// The return value is the address of a []string variable that stores the value of the config.
+func (f *ConfigSet) StringSlice(name string, value []string, usage string) *[]string {
+	p := new([]string)
+	f.StringSliceVar(p, name, value, usage)
+	return p
+}
+
+// StringSlice defines a []string config with specified name, default value, and usage string.
+// The return value is the address of a []string variable that stores the value of the config.
+func StringSlice(name string, value []string, usage string) *[]string {
+	return Configuration.StringSlice(name, value, usage)
+}
+
+// -- []int Value
+type intSliceValue struct {
+	value   *[]int
+	changed bool
+}
+
+func newIntSliceValue(val []int, p *[]int) *intSliceValue {
+	*p = val
+	return &intSliceValue{value: p}
+}
+
+// Set replaces the default with the comma-separated list val the first time
+// it is called, and appends to the list on later calls.
+func (s *intSliceValue) Set(val string) error {
+	v := []int{}
+	if val != "" {
+		for _, e := range strings.Split(val, ",") {
+			e = strings.TrimSpace(e)
+			n, err := strconv.ParseInt(e, 0, strconv.IntSize)
+			if err != nil {
+				return fmt.Errorf("invalid element %q: %w", e, err)
+			}
+			v = append(v, int(n))
+		}
+	}
+	if !s.changed {
+		*s.value = v
+		s.changed = true
+	} else {
+		*s.value = append(*s.value, v...)
+	}
+	return nil
+}
+
+// Append appends the list val, even if it has not been set before.
+func (s *intSliceValue) Append(val string) error {
+	changed := s.changed
+	s.changed = true
+	if err := s.Set(val); err != nil {
+		s.changed = changed
+		return err
+	}
+	return nil
+}
+
+func (s *intSliceValue) Get() interface{} { return *s.value }
+
+func (s *intSliceValue) newValue() Value { return newIntSliceValue(nil, new([]int)) }
+
+func (s *intSliceValue) reset(val string) error {
+	s.changed = false
+	err := s.Set(val)
+	s.changed = false
+	return err
+}
+
+func (s *intSliceValue) saveState() func() {
+	old, changed := append([]int(nil), *s.value...), s.changed
+	return func() { *s.value, s.changed = old, changed }
+}
+
+func (s *intSliceValue) String() string {
+	if s == nil || s.value == nil {
+		return ""
+	}
+	list := make([]string, len(*s.value))
+	for i, n := range *s.value {
+		list[i] = strconv.Itoa(n)
+	}
+	return strings.Join(list, ",")
+}
+
+// IntSliceVar defines a []int config with specified name, default value, and usage string.
+// The argument p points to a []int variable in which to store the value of the config.
+// The config accepts a comma-separated list of integers. Each occurrence after the
+// first appends to the list.
+func (f *ConfigSet) IntSliceVar(p *[]int, name string, value []int, usage string) {
+	f.Var(newIntSliceValue(value, p), name, usage)
+}
+
+// IntSliceVar defines a []int config with specified name, default value, and usage string.
+// The argument p points to a []int variable in which to store the value of the config.
+// The config accepts a comma-separated list of integers. Each occurrence after the
+// first appends to the list.
+func IntSliceVar(p *[]int, name string, value []int, usage string) {
+	Configuration.Var(newIntSliceValue(value, p), name, usage)
+}
+
+// IntSlice defines a []int config with specified name, default value, and usage string.
+// The return value is the address of a []int variable that stores the value of the config.
+func (f *ConfigSet) IntSlice(name string, value []int, usage string) *[]int {
+	p := new([]int)
+	f.IntSliceVar(p, name, value, usage)
+	return p
+}
+
+// IntSlice defines a []int config with specified name, default value, and usage string.
+// The return value is the address of a []int variable that stores the value of the config.
+func IntSlice(name string, value []int, usage string) *[]int {
+	return Configuration.IntSlice(name, value, usage)
+}
+
+// -- []time.Duration Value
+type durationSliceValue struct {
+	value   *[]time.Duration
+	changed bool
+}
+
+func newDurationSliceValue(val []time.Duration, p *[]time.Duration) *durationSliceValue {
+	*p = val
+	return &durationSliceValue{value: p}
+}
+
+// Set replaces the default with the comma-separated list val the first time
+// it is called, and appends to the list on later calls.
+func (s *durationSliceValue) Set(val string) error {
+	v := []time.Duration{}
+	if val != "" {
+		for _, e := range strings.Split(val, ",") {
+			e = strings.TrimSpace(e)
+			d, err := time.ParseDuration(e)
+			if err != nil {
+				return fmt.Errorf("invalid element %q: %w", e, err)
+			}
+			v = append(v, d)
+		}
+	}
+	if !s.changed {
+		*s.value = v
+		s.changed = true
+	} else {
+		*s.value = append(*s.value, v...)
+	}
+	return nil
+}
+
+// Append appends the list val, even if it has not been set before.
+func (s *durationSliceValue) Append(val string) error {
+	changed := s.changed
+	s.changed = true
+	if err := s.Set(val); err != nil {
+		s.changed = changed
+		return err
+	}
+	return nil
+}
+
+func (s *durationSliceValue) Get() interface{} { return *s.value }
+
+func (s *durationSliceValue) newValue() Value {
+	return newDurationSliceValue(nil, new([]time.Duration))
+}
+
+func (s *durationSliceValue) reset(val string) error {
+	s.changed = false
+	err := s.Set(val)
+	s.changed = false
+	return err
+}
+
+func (s *durationSliceValue) saveState() func() {
+	old, changed := append([]time.Duration(nil), *s.value...), s.changed
+	return func() { *s.value, s.changed = old, changed }
+}
+
+func (s *durationSliceValue) String() string {
+	if s == nil || s.value == nil {
+		return ""
+	}
+	list := make([]string, len(*s.value))
+	for i, d := range *s.value {
+		list[i] = d.String()
+	}
+	return strings.Join(list, ",")
+}
+
+// DurationSliceVar defines a []time.Duration config with specified name, default value, and usage string.
+// The argument p points to a []time.Duration variable in which to store the value of the config.
+// The config accepts a comma-separated list of durations valid for time.ParseDuration.
+// Each occurrence after the first appends to the list.
+func (f *ConfigSet) DurationSliceVar(p *[]time.Duration, name string, value []time.Duration, usage string) {
+	f.Var(newDurationSliceValue(value, p), name, usage)
+}
+
+// DurationSliceVar defines a []time.Duration config with specified name, default value, and usage string.
+// The argument p points to a []time.Duration variable in which to store the value of the config.
+// The config accepts a comma-separated list of durations valid for time.ParseDuration.
+// Each occurrence after the first appends to the list.
+func DurationSliceVar(p *[]time.Duration, name string, value []time.Duration, usage string) {
+	Configuration.Var(newDurationSliceValue(value, p), name, usage)
+}
+
+// DurationSlice defines a []time.Duration config with specified name, default value, and usage string.
+// The return value is the address of a []time.Duration variable that stores the value of the config.
+func (f *ConfigSet) DurationSlice(name string, value []time.Duration, usage string) *[]time.Duration {
+	p := new([]time.Duration)
+	f.DurationSliceVar(p, name, value, usage)
+	return p
+}
+
+// DurationSlice defines a []time.Duration config with specified name, default value, and usage string.
+// The return value is the address of a []time.Duration variable that stores the value of the config.
+func DurationSlice(name string, value []time.Duration, usage string) *[]time.Duration {
+	return Configuration.DurationSlice(name, value, usage)
+}
+
+// -- map[string]string Value
+type stringMapValue struct {
+	value   *map[string]string
+	changed bool
+}
+
+func newStringMapValue(val map[string]string, p *map[string]string) *stringMapValue {
+	*p = val
+	return &stringMapValue{value: p}
+}
+
+// Set replaces the default with the comma-separated key=value pairs in val
+// the first time it is called, and merges the pairs into the map on later
+// calls.
+func (m *stringMapValue) Set(val string) error {
+	v := make(map[string]string)
+	if val != "" {
+		for _, pair := range splitEscaped(val, ',', -1) {
+			kv := splitEscaped(pair, '=', 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("missing '=' in %q", pair)
+			}
+			v[unescape(kv[0], ",=")] = unescape(kv[1], ",=")
+		}
+	}
+	if m.changed {
+		// Merge into a copy so that earlier values of the map are unaffected.
+		for k, e := range *m.value {
+			if _, ok := v[k]; !ok {
+				v[k] = e
+			}
+		}
+	}
+	*m.value = v
+	m.changed = true
+	return nil
+}
+
+// Append merges the pairs in val into the map, even if it has not been set before.
+func (m *stringMapValue) Append(val string) error {
+	changed := m.changed
+	m.changed = true
+	if err := m.Set(val); err != nil {
+		m.changed = changed
+		return err
+	}
+	return nil
+}
+
+func (m *stringMapValue) Get() interface{} { return *m.value }
+
+func (m *stringMapValue) newValue() Value { return newStringMapValue(nil, new(map[string]string)) }
+
+func (m *stringMapValue) reset(val string) error {
+	m.changed = false
+	err := m.Set(val)
+	m.changed = false
+	return err
+}
+
+func (m *stringMapValue) saveState() func() {
+	var old map[string]string
+	if *m.value != nil {
+		old = make(map[string]string, len(*m.value))
+		for k, v := range *m.value {
+			old[k] = v
+		}
+	}
+	changed := m.changed
+	return func() { *m.value, m.changed = old, changed }
+}
+
+// String returns the pairs sorted by key.
+func (m *stringMapValue) String() string {
+	if m == nil || m.value == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(*m.value))
+	for k := range *m.value {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = escape(k, ",=") + "=" + escape((*m.value)[k], ",=")
+	}
+	return strings.Join(pairs, ",")
+}
+
+// StringMapVar defines a map[string]string config with specified name, default value, and usage string.
+// The argument p points to a map[string]string variable in which to store the value of the config.
+// The config accepts comma-separated key=value pairs; a comma, equals sign or backslash
+// within a key or value is escaped with a backslash. Each occurrence after the first
+// merges its pairs into the map.
+func (f *ConfigSet) StringMapVar(p *map[string]string, name string, value map[string]string, usage string) {
+	f.Var(newStringMapValue(value, p), name, usage)
+}
+
+// StringMapVar defines a map[string]string config with specified name, default value, and usage string.
+// The argument p points to a map[string]string variable in which to store the value of the config.
+// The config accepts comma-separated key=value pairs; a comma, equals sign or backslash
+// within a key or value is escaped with a backslash. Each occurrence after the first
+// merges its pairs into the map.
+func StringMapVar(p *map[string]string, name string, value map[string]string, usage string) {
+	Configuration.Var(newStringMapValue(value, p), name, usage)
+}
+
+// StringMap defines a map[string]string config with specified name, default value, and usage string.
+// The return value is the address of a map[string]string variable that stores the value of the config.
+func (f *ConfigSet) StringMap(name string, value map[string]string, usage string) *map[string]string {
+	p := new(map[string]string)
+	f.StringMapVar(p, name, value, usage)
+	return p
+}
+
+// StringMap defines a map[string]string config with specified name, default value, and usage string.
+// The return value is the address of a map[string]string variable that stores the value of the config.
+func StringMap(name string, value map[string]string, usage string) *map[string]string {
+	return Configuration.StringMap(name, value, usage)
+}
+
+// -- net.IP Value
+type ipValue net.IP
+
+func newIPValue(val net.IP, p *net.IP) *ipValue {
+	*p = val
+	return (*ipValue)(p)
+}
+
+func (i *ipValue) Set(s string) error {
+	if s == "" {
+		*i = nil
+		return nil
+	}
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip == nil {
+		return fmt.Errorf("invalid IP address %q", s)
+	}
+	*i = ipValue(ip)
+	return nil
+}
+
+func (i *ipValue) Get() interface{} { return net.IP(*i) }
+
+func (i *ipValue) String() string {
+	if i == nil || *i == nil {
+		return ""
+	}
+	return net.IP(*i).String()
+}
+
+// IPVar defines a net.IP config with specified name, default value, and usage string.
+// The argument p points to a net.IP variable in which to store the value of the config.
+// The config accepts an IPv4 or IPv6 address in any form accepted by net.ParseIP.
+func (f *ConfigSet) IPVar(p *net.IP, name string, value net.IP, usage string) {
+	f.Var(newIPValue(value, p), name, usage)
+}
+
+// IPVar defines a net.IP config with specified name, default value, and usage string.
+// The argument p points to a net.IP variable in which to store the value of the config.
+// The config accepts an IPv4 or IPv6 address in any form accepted by net.ParseIP.
+func IPVar(p *net.IP, name string, value net.IP, usage string) {
+	Configuration.Var(newIPValue(value, p), name, usage)
+}
+
+// IP defines a net.IP config with specified name, default value, and usage string.
+// The return value is the address of a net.IP variable that stores the value of the config.
+func (f *ConfigSet) IP(name string, value net.IP, usage string) *net.IP {
+	p := new(net.IP)
+	f.IPVar(p, name, value, usage)
+	return p
+}
+
+// IP defines a net.IP config with specified name, default value, and usage string.
+// The return value is the address of a net.IP variable that stores the value of the config.
+func IP(name string, value net.IP, usage string) *net.IP {
+	return Configuration.IP(name, value, usage)
+}
+
+// -- net.IPNet Value
+type ipNetValue net.IPNet
+
+func newIPNetValue(val net.IPNet, p *net.IPNet) *ipNetValue {
+	*p = val
+	return (*ipNetValue)(p)
+}
+
+func (n *ipNetValue) Set(s string) error {
+	if s == "" {
+		*n = ipNetValue{}
+		return nil
+	}
+	_, ipnet, err := net.ParseCIDR(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	*n = ipNetValue(*ipnet)
+	return nil
+}
+
+func (n *ipNetValue) Get() interface{} { return net.IPNet(*n) }
+
+func (n *ipNetValue) String() string {
+	if n == nil || n.IP == nil {
+		return ""
+	}
+	return (*net.IPNet)(n).String()
+}
+
+// IPNetVar defines a net.IPNet config with specified name, default value, and usage string.
+// The argument p points to a net.IPNet variable in which to store the value of the config.
+// The config accepts CIDR notation as accepted by net.ParseCIDR, such as 10.0.0.0/8;
+// host bits are cleared, so 10.1.2.3/8 is stored as 10.0.0.0/8.
+func (f *ConfigSet) IPNetVar(p *net.IPNet, name string, value net.IPNet, usage string) {
+	f.Var(newIPNetValue(value, p), name, usage)
+}
+
+// IPNetVar defines a net.IPNet config with specified name, default value, and usage string.
+// The argument p points to a net.IPNet variable in which to store the value of the config.
+// The config accepts CIDR notation as accepted by net.ParseCIDR, such as 10.0.0.0/8;
+// host bits are cleared, so 10.1.2.3/8 is stored as 10.0.0.0/8.
+func IPNetVar(p *net.IPNet, name string, value net.IPNet, usage string) {
+	Configuration.Var(newIPNetValue(value, p), name, usage)
+}
+
+// IPNet defines a net.IPNet config with specified name, default value, and usage string.
+// The return value is the address of a net.IPNet variable that stores the value of the config.
+func (f *ConfigSet) IPNet(name string, value net.IPNet, usage string) *net.IPNet {
+	p := new(net.IPNet)
+	f.IPNetVar(p, name, value, usage)
+	return p
+}
+
+// IPNet defines a net.IPNet config with specified name, default value, and usage string.
+// The return value is the address of a net.IPNet variable that stores the value of the config.
+func IPNet(name string, value net.IPNet, usage string) *net.IPNet {
+	return Configuration.IPNet(name, value, usage)
+}
+
+// -- time.Time Value
+type timeValue struct {
+	value  *time.Time
+	layout string
+}
+
+func newTimeValue(val time.Time, p *time.Time, layout string) *timeValue {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	*p = val
+	return &timeValue{value: p, layout: layout}
+}
+
+func (t *timeValue) Set(s string) error {
+	if s == "now" {
+		*t.value = time.Now()
+		return nil
+	}
+	v, err := time.Parse(t.layout, strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("invalid time %q, expected layout %q", s, t.layout)
+	}
+	*t.value = v
+	return nil
+}
+
+func (t *timeValue) Get() interface{} { return *t.value }
+
+func (t *timeValue) newValue() Value { return newTimeValue(time.Time{}, new(time.Time), t.layout) }
+
+func (t *timeValue) String() string {
+	if t == nil || t.value == nil {
+		return ""
+	}
+	return t.value.Format(t.layout)
+}
+
+// TimeVar defines a time.Time config with specified name, default value, layout, and usage string.
+// The argument p points to a time.Time variable in which to store the value of the config.
+// The config accepts a time formatted according to layout, or RFC 3339 if layout is empty,
+// and the special value "now" for the time at which it is set. As with time.Parse, a time
+// without a zone offset is taken to be UTC, and one with an offset is given a fixed zone
+// rather than the local time zone.
+func (f *ConfigSet) TimeVar(p *time.Time, name string, value time.Time, layout, usage string) {
+	f.Var(newTimeValue(value, p, layout), name, usage)
+}
+
+// TimeVar defines a time.Time config with specified name, default value, layout, and usage string.
+// The argument p points to a time.Time variable in which to store the value of the config.
+// The config accepts a time formatted according to layout, or RFC 3339 if layout is empty,
+// and the special value "now" for the time at which it is set. As with time.Parse, a time
+// without a zone offset is taken to be UTC, and one with an offset is given a fixed zone
+// rather than the local time zone.
+func TimeVar(p *time.Time, name string, value time.Time, layout, usage string) {
+	Configuration.Var(newTimeValue(value, p, layout), name, usage)
+}
+
+// Time defines a time.Time config with specified name, default value, layout, and usage string.
+// The return value is the address of a time.Time variable that stores the value of the config.
+func (f *ConfigSet) Time(name string, value time.Time, layout, usage string) *time.Time {
+	p := new(time.Time)
+	f.TimeVar(p, name, value, layout, usage)
+	return p
+}
+
+// Time defines a time.Time config with specified name, default value, layout, and usage string.
+// The return value is the address of a time.Time variable that stores the value of the config.
+func Time(name string, value time.Time, layout, usage string) *time.Time {
+	return Configuration.Time(name, value, layout, usage)
+}
+
+// byteUnits lists the size suffixes accepted by a bytes config.
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"B", 1},
+	{"KB", 1e3}, {"KiB", 1 << 10},
+	{"MB", 1e6}, {"MiB", 1 << 20},
+	{"GB", 1e9}, {"GiB", 1 << 30},
+	{"TB", 1e12}, {"TiB", 1 << 40},
+	{"PB", 1e15}, {"PiB", 1 << 50},
+	{"EB", 1e18}, {"EiB", 1 << 60},
+}
+
+// parseBytes parses a size such as 1024, 4MB or 1.5GiB into a byte count.
+func parseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "-") {
+		return 0, fmt.Errorf("negative size %q", s)
+	}
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	num, suffix := s[:i], strings.TrimSpace(s[i:])
+	size := int64(0)
+	if suffix == "" {
+		size = 1
+	}
+	for _, u := range byteUnits {
+		if strings.EqualFold(suffix, u.suffix) {
+			size = u.size
+		}
+	}
+	if num == "" || size == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if strings.Contains(num, ".") {
+		v, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid size %q", s)
+		}
+		v *= float64(size)
+		if v >= math.MaxInt64 {
+			return 0, fmt.Errorf("size %q out of range", s)
+		}
+		return int64(math.Round(v)), nil
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n > math.MaxInt64/size {
+		return 0, fmt.Errorf("size %q out of range", s)
+	}
+	return n * size, nil
+}
+
+// formatBytes returns the shortest exact representation of n as a size,
+// preferring a unit suffix over a plain byte count of the same length.
+func formatBytes(n int64) string {
+	plain := strconv.FormatInt(n, 10)
+	if n == 0 {
+		return plain
+	}
+	best := plain
+	for _, u := range byteUnits[1:] {
+		if n%u.size != 0 {
+			continue
+		}
+		s := strconv.FormatInt(n/u.size, 10) + u.suffix
+		if len(s) < len(best) || best == plain && len(s) == len(best) {
+			best = s
+		}
+	}
+	return best
+}
+
+// -- bytes Value
+type bytesValue int64
+
+func newBytesValue(val int64, p *int64) *bytesValue {
+	*p = val
+	return (*bytesValue)(p)
+}
+
+func (b *bytesValue) Set(s string) error {
+	v, err := parseBytes(s)
+	if err != nil {
+		return err
+	}
+	*b = bytesValue(v)
+	return nil
+}
+
+func (b *bytesValue) Get() interface{} { return int64(*b) }
+
+func (b *bytesValue) String() string { return formatBytes(int64(*b)) }
+
+// BytesVar defines a byte-size config with specified name, default value, and usage string.
+// The argument p points to an int64 variable in which to store the number of bytes.
+// The config accepts a plain byte count or a number with one of the case-insensitive
+// suffixes B, KB, MB, GB, TB, PB and EB (powers of 1000) or KiB, MiB, GiB, TiB, PiB
+// and EiB (powers of 1024), such as 32MB or 1.5GiB.
+func (f *ConfigSet) BytesVar(p *int64, name string, value int64, usage string) {
+	f.Var(newBytesValue(value, p), name, usage)
+}
+
+// BytesVar defines a byte-size config with specified name, default value, and usage string.
+// The argument p points to an int64 variable in which to store the number of bytes.
+// The config accepts a plain byte count or a number with one of the case-insensitive
+// suffixes B, KB, MB, GB, TB, PB and EB (powers of 1000) or KiB, MiB, GiB, TiB, PiB
+// and EiB (powers of 1024), such as 32MB or 1.5GiB.
+func BytesVar(p *int64, name string, value int64, usage string) {
+	Configuration.Var(newBytesValue(value, p), name, usage)
+}
+
+// Bytes defines a byte-size config with specified name, default value, and usage string.
+// The return value is the address of an int64 variable that stores the number of bytes.
+func (f *ConfigSet) Bytes(name string, value int64, usage string) *int64 {
+	p := new(int64)
+	f.BytesVar(p, name, value, usage)
+	return p
+}
+
+// Bytes defines a byte-size config with specified name, default value, and usage string.
+// The return value is the address of an int64 variable that stores the number of bytes.
+func Bytes(name string, value int64, usage string) *int64 {
+	return Configuration.Bytes(name, value, usage)
+}
+
+// -- enum Value
+type enumValue struct {
+	value   *string
+	allowed []string
+}
+
+func newEnumValue(val string, p *string, allowed []string) *enumValue {
+	*p = val
+	return &enumValue{value: p, allowed: allowed}
+}
+
+func (e *enumValue) Set(s string) error {
+	for _, a := range e.allowed {
+		if s == a {
+			*e.value = s
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %q, must be one of %s", s, strings.Join(e.allowed, ", "))
+}
+
+func (e *enumValue) Get() interface{} { return *e.value }
+
+func (e *enumValue) newValue() Value { return newEnumValue("", new(string), e.allowed) }
+
+func (e *enumValue) String() string {
+	if e == nil || e.value == nil {
+		return ""
+	}
+	return *e.value
+}
+
+// enumUsage returns usage with the allowed values appended.
+func enumUsage(allowed []string, usage string) string {
+	choices := "one of " + strings.Join(allowed, "|")
+	if usage == "" {
+		return choices
+	}
+	return usage + " (" + choices + ")"
+}
+
+// EnumVar defines a string config with specified name, allowed values, default value, and usage string.
+// The argument p points to a string variable in which to store the value of the config.
+// Setting the config to a value not in allowed is an error, and EnumVar panics if the
+// default value is not in allowed. The allowed values are appended to the usage string.
+func (f *ConfigSet) EnumVar(p *string, name string, allowed []string, value string, usage string) {
+	v := newEnumValue(value, p, allowed)
+	if err := v.Set(value); err != nil {
+		panic(fmt.Sprintf("config %s: default value %q is not one of %s", name, value, strings.Join(allowed, ", ")))
+	}
+	f.Var(v, name, enumUsage(allowed, usage))
+}
+
+// EnumVar defines a string config with specified name, allowed values, default value, and usage string.
+// The argument p points to a string variable in which to store the value of the config.
+// Setting the config to a value not in allowed is an error, and EnumVar panics if the
+// default value is not in allowed. The allowed values are appended to the usage string.
+func EnumVar(p *string, name string, allowed []string, value string, usage string) {
+	Configuration.EnumVar(p, name, allowed, value, usage)
+}
+
+// Enum defines a string config with specified name, allowed values, default value, and usage string.
+// The return value is the address of a string variable that stores the value of the config.
+func (f *ConfigSet) Enum(name string, allowed []string, value string, usage string) *string {
+	p := new(string)
+	f.EnumVar(p, name, allowed, value, usage)
+	return p
+}
+
+// Enum defines a string config with specified name, allowed values, default value, and usage string.
+// The return value is the address of a string variable that stores the value of the config.
+func Enum(name string, allowed []string, value string, usage string) *string {
+	return Configuration.Enum(name, allowed, value, usage)
+}
+
+// -- level Value
+type levelValue struct {
+	value   *int
+	mapping map[string]int
+}
+
+func newLevelValue(val int, p *int, mapping map[string]int) *levelValue {
+	*p = val
+	return &levelValue{value: p, mapping: mapping}
+}
+
+func (l *levelValue) Set(s string) error {
+	for name, n := range l.mapping {
+		if strings.EqualFold(s, name) {
+			*l.value = n
+			return nil
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		*l.value = n
+		return nil
+	}
+	return fmt.Errorf("unknown level %q, must be one of %s", s, strings.Join(levelNames(l.mapping), ", "))
+}
+
+func (l *levelValue) Get() interface{} { return *l.value }
+
+func (l *levelValue) newValue() Value { return newLevelValue(0, new(int), l.mapping) }
+
+// String returns the name of the level, the first in order of name if it
+// has several, or the number if it has none.
+func (l *levelValue) String() string {
+	if l == nil || l.value == nil {
+		return ""
+	}
+	name := ""
+	for n, v := range l.mapping {
+		if v == *l.value && (name == "" || n < name) {
+			name = n
+		}
+	}
+	if name == "" {
+		return strconv.Itoa(*l.value)
+	}
+	return name
+}
+
+// levelNames returns the names in mapping in order of level, and of name
+// for the same level.
+func levelNames(mapping map[string]int) []string {
+	names := make([]string, 0, len(mapping))
+	for name := range mapping {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if mapping[names[i]] != mapping[names[j]] {
+			return mapping[names[i]] < mapping[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// LevelVar defines an int config with specified name, level names, default value, and usage string.
+// The argument p points to an int variable in which to store the value of the config.
+// The config accepts a name in mapping, in any case, and stores the level it maps to, or a
+// level given as a number; it is written as the level's name. The names are appended to the
+// usage string.
+func (f *ConfigSet) LevelVar(p *int, name string, mapping map[string]int, value int, usage string) {
+	m := make(map[string]int, len(mapping))
+	for k, v := range mapping {
+		m[k] = v
+	}
+	f.Var(newLevelValue(value, p, m), name, enumUsage(levelNames(m), usage))
+}
+
+// LevelVar defines an int config with specified name, level names, default value, and usage string.
+// The argument p points to an int variable in which to store the value of the config.
+// The config accepts a name in mapping, in any case, and stores the level it maps to, or a
+// level given as a number; it is written as the level's name. The names are appended to the
+// usage string.
+func LevelVar(p *int, name string, mapping map[string]int, value int, usage string) {
+	Configuration.LevelVar(p, name, mapping, value, usage)
+}
+
+// Level defines an int config with specified name, level names, default value, and usage string.
+// The return value is the address of an int variable that stores the level.
+func (f *ConfigSet) Level(name string, mapping map[string]int, value int, usage string) *int {
+	p := new(int)
+	f.LevelVar(p, name, mapping, value, usage)
+	return p
+}
+
+// Level defines an int config with specified name, level names, default value, and usage string.
+// The return value is the address of an int variable that stores the level.
+func Level(name string, mapping map[string]int, value int, usage string) *int {
+	return Configuration.Level(name, mapping, value, usage)
+}
+
+// -- path Value
+type pathValue struct {
+	value     *string
+	mustExist bool
+	dirOK     bool
+}
+
+func newPathValue(val string, p *string, mustExist, dirOK bool) *pathValue {
+	*p = val
+	return &pathValue{value: p, mustExist: mustExist, dirOK: dirOK}
+}
+
+func (v *pathValue) Set(s string) error {
+	if s != "" {
+		fi, err := os.Stat(s)
+		switch {
+		case err != nil && (v.mustExist || !os.IsNotExist(err)):
+			return err
+		case err == nil && fi.IsDir() && !v.dirOK:
+			return fmt.Errorf("%s is a directory", s)
+		}
+	}
+	*v.value = s
+	return nil
+}
+
+func (v *pathValue) Get() interface{} { return *v.value }
+
+func (v *pathValue) newValue() Value { return newPathValue("", new(string), v.mustExist, v.dirOK) }
+
+func (v *pathValue) String() string {
+	if v == nil || v.value == nil {
+		return ""
+	}
+	return *v.value
+}
+
+// PathVar defines a file path config with specified name, default value, and usage string.
+// The argument p points to a string variable in which to store the value of the config.
+// If mustExist is true, setting the config to a path that does not exist is an error.
+// If dirOK is false, setting it to a path that is a directory is an error. An empty
+// value is always accepted. The default value is not checked.
+func (f *ConfigSet) PathVar(p *string, name string, value string, usage string, mustExist bool, dirOK bool) {
+	f.Var(newPathValue(value, p, mustExist, dirOK), name, usage)
+}
+
+// PathVar defines a file path config with specified name, default value, and usage string.
+// The argument p points to a string variable in which to store the value of the config.
+// If mustExist is true, setting the config to a path that does not exist is an error.
+// If dirOK is false, setting it to a path that is a directory is an error. An empty
+// value is always accepted. The default value is not checked.
+func PathVar(p *string, name string, value string, usage string, mustExist bool, dirOK bool) {
+	Configuration.PathVar(p, name, value, usage, mustExist, dirOK)
+}
+
+// Path defines a file path config with specified name, default value, and usage string.
+// The return value is the address of a string variable that stores the value of the config.
+func (f *ConfigSet) Path(name string, value string, usage string, mustExist bool, dirOK bool) *string {
+	p := new(string)
+	f.PathVar(p, name, value, usage, mustExist, dirOK)
+	return p
+}
+
+// Path defines a file path config with specified name, default value, and usage string.
+// The return value is the address of a string variable that stores the value of the config.
+func Path(name string, value string, usage string, mustExist bool, dirOK bool) *string {
+	return Configuration.Path(name, value, usage, mustExist, dirOK)
+}
+
+// -- encoded []byte Value
+type encodedBytesValue struct {
+	value  *[]byte
+	length int  // required length in bytes, or 0 for any
+	base64 bool // whether the bytes are written in base64 rather than hex
+}
+
+func newEncodedBytesValue(val []byte, p *[]byte, length int, base64 bool) *encodedBytesValue {
+	*p = val
+	return &encodedBytesValue{value: p, length: length, base64: base64}
+}
+
+func (b *encodedBytesValue) Set(s string) error {
+	if s == "" {
+		*b.value = nil
+		return nil
+	}
+	var v []byte
+	var err error
+	if b.base64 {
+		v, err = base64.StdEncoding.DecodeString(s)
+	} else {
+		v, err = hex.DecodeString(s)
+	}
+	if err != nil {
+		return err
+	}
+	if b.length > 0 && len(v) != b.length {
+		return fmt.Errorf("got %d bytes, want %d", len(v), b.length)
+	}
+	*b.value = v
+	return nil
+}
+
+func (b *encodedBytesValue) Get() interface{} { return *b.value }
+
+func (b *encodedBytesValue) newValue() Value {
+	return newEncodedBytesValue(nil, new([]byte), b.length, b.base64)
+}
+
+func (b *encodedBytesValue) String() string {
+	if b == nil || b.value == nil {
+		return ""
+	}
+	if b.base64 {
+		return base64.StdEncoding.EncodeToString(*b.value)
+	}
+	return hex.EncodeToString(*b.value)
+}
+
+func (b *encodedBytesValue) typeName() string {
+	if b.base64 {
+		return "base64"
+	}
+	return "hex"
+}
+
+// BytesHexVar defines a []byte config with specified name, length, default value, and usage string.
+// The argument p points to a []byte variable in which to store the value of the config.
+// The config accepts the bytes in hexadecimal and is written that way. If length is not 0,
+// setting the config to a value of another length in bytes is an error. An empty value sets
+// it to nil.
+func (f *ConfigSet) BytesHexVar(p *[]byte, name string, length int, value []byte, usage string) {
+	f.Var(newEncodedBytesValue(value, p, length, false), name, usage)
+}
+
+// BytesHexVar defines a []byte config with specified name, length, default value, and usage string.
+// The argument p points to a []byte variable in which to store the value of the config.
+// The config accepts the bytes in hexadecimal and is written that way. If length is not 0,
+// setting the config to a value of another length in bytes is an error. An empty value sets
+// it to nil.
+func BytesHexVar(p *[]byte, name string, length int, value []byte, usage string) {
+	Configuration.BytesHexVar(p, name, length, value, usage)
+}
+
+// BytesHex defines a []byte config with specified name, length, default value, and usage string.
+// The return value is the address of a []byte variable that stores the value of the config.
+func (f *ConfigSet) BytesHex(name string, length int, value []byte, usage string) *[]byte {
+	p := new([]byte)
+	f.BytesHexVar(p, name, length, value, usage)
+	return p
+}
+
+// BytesHex defines a []byte config with specified name, length, default value, and usage string.
+// The return value is the address of a []byte variable that stores the value of the config.
+func BytesHex(name string, length int, value []byte, usage string) *[]byte {
+	return Configuration.BytesHex(name, length, value, usage)
+}
+
+// BytesBase64Var defines a []byte config with specified name, length, default value, and usage string.
+// The argument p points to a []byte variable in which to store the value of the config.
+// The config accepts the bytes in standard base64 encoding and is written that way. If length
+// is not 0, setting the config to a value of another length in bytes is an error. An empty
+// value sets it to nil.
+func (f *ConfigSet) BytesBase64Var(p *[]byte, name string, length int, value []byte, usage string) {
+	f.Var(newEncodedBytesValue(value, p, length, true), name, usage)
+}
+
+// BytesBase64Var defines a []byte config with specified name, length, default value, and usage string.
+// The argument p points to a []byte variable in which to store the value of the config.
+// The config accepts the bytes in standard base64 encoding and is written that way. If length
+// is not 0, setting the config to a value of another length in bytes is an error. An empty
+// value sets it to nil.
+func BytesBase64Var(p *[]byte, name string, length int, value []byte, usage string) {
+	Configuration.BytesBase64Var(p, name, length, value, usage)
+}
+
+// BytesBase64 defines a []byte config with specified name, length, default value, and usage string.
+// The return value is the address of a []byte variable that stores the value of the config.
+func (f *ConfigSet) BytesBase64(name string, length int, value []byte, usage string) *[]byte {
+	p := new([]byte)
+	f.BytesBase64Var(p, name, length, value, usage)
+	return p
+}
+
+// BytesBase64 defines a []byte config with specified name, length, default value, and usage string.
+// The return value is the address of a []byte variable that stores the value of the config.
+func BytesBase64(name string, length int, value []byte, usage string) *[]byte {
+	return Configuration.BytesBase64(name, length, value, usage)
+}
+
+// -- *url.URL Value
+type urlValue struct {
+	value           **url.URL
+	requireAbsolute bool
+}
+
+func newURLValue(val *url.URL, p **url.URL, requireAbsolute bool) *urlValue {
+	*p = val
+	return &urlValue{value: p, requireAbsolute: requireAbsolute}
+}
+
+func (u *urlValue) Set(s string) error {
+	if s == "" {
+		*u.value = nil
+		return nil
+	}
+	v, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	if u.requireAbsolute {
+		if v.Scheme == "" {
+			return fmt.Errorf("URL %q has no scheme", s)
+		}
+		if v.Host == "" {
+			return fmt.Errorf("URL %q has no host", s)
+		}
+	}
+	*u.value = v
+	return nil
+}
+
+func (u *urlValue) Get() interface{} { return *u.value }
+
+func (u *urlValue) newValue() Value { return newURLValue(nil, new(*url.URL), u.requireAbsolute) }
+
+func (u *urlValue) String() string {
+	if u == nil || u.value == nil || *u.value == nil {
+		return ""
+	}
+	return (*u.value).String()
+}
+
+// URLVar defines a *url.URL config with specified name, default value, and usage string.
+// The argument p points to a *url.URL variable in which to store the value of the config.
+// The config accepts any input valid for url.Parse; an empty value sets it to nil.
+func (f *ConfigSet) URLVar(p **url.URL, name string, value *url.URL, usage string) {
+	f.Var(newURLValue(value, p, false), name, usage)
+}
+
+// URLVar defines a *url.URL config with specified name, default value, and usage string.
+// The argument p points to a *url.URL variable in which to store the value of the config.
+// The config accepts any input valid for url.Parse; an empty value sets it to nil.
+func URLVar(p **url.URL, name string, value *url.URL, usage string) {
+	Configuration.Var(newURLValue(value, p, false), name, usage)
+}
+
+// URL defines a *url.URL config with specified name, default value, and usage string.
+// The return value is the address of a *url.URL variable that stores the value of the config.
+func (f *ConfigSet) URL(name string, value *url.URL, usage string) **url.URL {
+	p := new(*url.URL)
+	f.URLVar(p, name, value, usage)
+	return p
+}
+
+// URL defines a *url.URL config with specified name, default value, and usage string.
+// The return value is the address of a *url.URL variable that stores the value of the config.
+func URL(name string, value *url.URL, usage string) **url.URL {
+	return Configuration.URL(name, value, usage)
+}
+
+// AbsURLVar is like URLVar but the config only accepts URLs that have both
+// a scheme and a host, such as https://example.com/api.
+func (f *ConfigSet) AbsURLVar(p **url.URL, name string, value *url.URL, usage string) {
+	f.Var(newURLValue(value, p, true), name, usage)
+}
+
+// AbsURLVar is like URLVar but the config only accepts URLs that have both
+// a scheme and a host, such as https://example.com/api.
+func AbsURLVar(p **url.URL, name string, value *url.URL, usage string) {
+	Configuration.Var(newURLValue(value, p, true), name, usage)
+}
+
+// AbsURL is like URL but the config only accepts URLs that have both
+// a scheme and a host, such as https://example.com/api.
+func (f *ConfigSet) AbsURL(name string, value *url.URL, usage string) **url.URL {
+	p := new(*url.URL)
+	f.AbsURLVar(p, name, value, usage)
+	return p
+}
+
+// AbsURL is like URL but the config only accepts URLs that have both
+// a scheme and a host, such as https://example.com/api.
+func AbsURL(name string, value *url.URL, usage string) **url.URL {
+	return Configuration.AbsURL(name, value, usage)
+}
+
+// -- *regexp.Regexp Value
+type regexpValue struct {
+	value **regexp.Regexp
+}
+
+func newRegexpValue(val *regexp.Regexp, p **regexp.Regexp) *regexpValue {
+	*p = val
+	return &regexpValue{value: p}
+}
+
+func (r *regexpValue) Set(s string) error {
+	if s == "" {
+		*r.value = nil
+		return nil
+	}
+	v, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	*r.value = v
+	return nil
+}
+
+func (r *regexpValue) Get() interface{} { return *r.value }
+
+func (r *regexpValue) newValue() Value { return newRegexpValue(nil, new(*regexp.Regexp)) }
+
+func (r *regexpValue) String() string {
+	if r == nil || r.value == nil || *r.value == nil {
+		return ""
+	}
+	return (*r.value).String()
+}
+
+// RegexpVar defines a *regexp.Regexp config with specified name, default value, and usage string.
+// The argument p points to a *regexp.Regexp variable in which to store the value of the config.
+// The config accepts any pattern accepted by regexp.Compile; an empty value sets it to nil.
+func (f *ConfigSet) RegexpVar(p **regexp.Regexp, name string, value *regexp.Regexp, usage string) {
+	f.Var(newRegexpValue(value, p), name, usage)
+}
+
+// RegexpVar defines a *regexp.Regexp config with specified name, default value, and usage string.
+// The argument p points to a *regexp.Regexp variable in which to store the value of the config.
+// The config accepts any pattern accepted by regexp.Compile; an empty value sets it to nil.
+func RegexpVar(p **regexp.Regexp, name string, value *regexp.Regexp, usage string) {
+	Configuration.Var(newRegexpValue(value, p), name, usage)
+}
+
+// Regexp defines a *regexp.Regexp config with specified name, default value, and usage string.
+// The return value is the address of a *regexp.Regexp variable that stores the value of the config.
+func (f *ConfigSet) Regexp(name string, value *regexp.Regexp, usage string) **regexp.Regexp {
+	p := new(*regexp.Regexp)
+	f.RegexpVar(p, name, value, usage)
+	return p
+}
+
+// Regexp defines a *regexp.Regexp config with specified name, default value, and usage string.
+// The return value is the address of a *regexp.Regexp variable that stores the value of the config.
+func Regexp(name string, value *regexp.Regexp, usage string) **regexp.Regexp {
+	return Configuration.Regexp(name, value, usage)
+}
+
+// -- count Value
+type countValue int
+
+func newCountValue(val int, p *int) *countValue {
+	*p = val
+	return (*countValue)(p)
+}
+
+// Set increments the count for "true", which the parser passes for a bare
+// -name, resets it for "false", and otherwise sets it to the given number.
+func (c *countValue) Set(s string) error {
+	if n, err := strconv.Atoi(s); err == nil {
+		*c = countValue(n)
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid count %q", s)
+	}
+	if b {
+		*c++
+	} else {
+		*c = 0
+	}
+	return nil
+}
+
+func (c *countValue) Get() interface{} { return int(*c) }
+
+func (c *countValue) String() string { return strconv.Itoa(int(*c)) }
+
+func (c *countValue) IsBoolConfig() bool { return true }
+
+// CountVar defines a counting config with specified name and usage string.
+// The argument p points to an int variable in which to store the count, which starts at 0.
+// Each -name on the command line adds one to the count, so -v -v -v yields 3;
+// -name=n sets the count to n.
+func (f *ConfigSet) CountVar(p *int, name string, usage string) {
+	f.Var(newCountValue(0, p), name, usage)
+}
+
+// CountVar defines a counting config with specified name and usage string.
+// The argument p points to an int variable in which to store the count, which starts at 0.
+// Each -name on the command line adds one to the count, so -v -v -v yields 3;
+// -name=n sets the count to n.
+func CountVar(p *int, name string, usage string) {
+	Configuration.Var(newCountValue(0, p), name, usage)
+}
+
+// Count defines a counting config with specified name and usage string.
+// The return value is the address of an int variable that stores the count.
+func (f *ConfigSet) Count(name string, usage string) *int {
+	p := new(int)
+	f.CountVar(p, name, usage)
+	return p
+}
+
+// Count defines a counting config with specified name and usage string.
+// The return value is the address of an int variable that stores the count.
+func Count(name string, usage string) *int {
+	return Configuration.Count(name, usage)
+}
+
+// -- encoding.TextUnmarshaler Value
+type textValue struct {
+	p encoding.TextUnmarshaler
+}
+
+func (v *textValue) Set(s string) error {
+	return v.p.UnmarshalText([]byte(s))
+}
+
+func (v *textValue) Get() interface{} { return v.p }
+
+func (v *textValue) newValue() Value {
+	t := reflect.TypeOf(v.p)
+	if t.Kind() != reflect.Ptr {
+		return nil
+	}
+	p, ok := reflect.New(t.Elem()).Interface().(encoding.TextUnmarshaler)
+	if !ok {
+		return nil
+	}
+	return &textValue{p}
+}
+
+func (v *textValue) String() string {
+	if v == nil || v.p == nil {
+		return ""
+	}
+	if m, ok := v.p.(encoding.TextMarshaler); ok {
+		if b, err := m.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(reflect.Indirect(reflect.ValueOf(v.p)).Interface())
+}
+
+// TextVar defines a config with specified name and usage string whose value
+// is set by the UnmarshalText method of p. The default value is the value p
+// holds when TextVar is called. The config is written by its MarshalText
+// method if it has one, and otherwise by fmt.Sprint of the value p points to.
+func (f *ConfigSet) TextVar(p encoding.TextUnmarshaler, name string, usage string) {
+	f.Var(&textValue{p}, name, usage)
+}
+
+// TextVar defines a config with specified name and usage string whose value
+// is set by the UnmarshalText method of p. The default value is the value p
+// holds when TextVar is called. The config is written by its MarshalText
+// method if it has one, and otherwise by fmt.Sprint of the value p points to.
+func TextVar(p encoding.TextUnmarshaler, name string, usage string) {
+	Configuration.Var(&textValue{p}, name, usage)
+}
+
+// -- func Value
+type funcValue func(string) error
+
+func (fn funcValue) Set(s string) error { return fn(s) }
+
+func (fn funcValue) Get() interface{} { return nil }
+
+func (fn funcValue) String() string { return "" }
+
+// saveState returns a restore function that does nothing, since the
+// effects of fn cannot be undone.
+func (fn funcValue) saveState() func() { return func() {} }
+
+// reset ignores the empty string, which is what String always returns, so
+// that resetting the config to its default or copying it does not call fn.
+func (fn funcValue) reset(s string) error {
+	if s == "" {
+		return nil
+	}
+	return fn(s)
+}
+
+func (fn funcValue) newValue() Value { return fn }
+
+// Func defines a config with the specified name and usage string.
+// Each time the config is set, fn is called with the config's value.
+// If fn returns a non-nil error, it will be treated as a config value parsing error.
+// Having no value of its own, the config is left out by Save, SaveJSON and
+// SaveTOML.
+func (f *ConfigSet) Func(name, usage string, fn func(string) error) {
+	f.Var(funcValue(fn), name, usage)
+}
+
+// Func defines a config with the specified name and usage string.
+// Each time the config is set, fn is called with the config's value.
+// If fn returns a non-nil error, it will be treated as a config value parsing error.
+// Having no value of its own, the config is left out by Save, SaveJSON and
+// SaveTOML.
+func Func(name, usage string, fn func(string) error) {
+	Configuration.Func(name, usage, fn)
+}
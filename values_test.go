@@ -0,0 +1,895 @@
+package goflagconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStringSlice(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"default"}},
+		{[]string{"-s=a"}, []string{"a"}},
+		{[]string{"-s=a,b", "-s=c"}, []string{"a", "b", "c"}},
+		{[]string{`-s=a\,b,c`}, []string{"a,b", "c"}},
+		{[]string{`-s=a\\,b`}, []string{`a\`, "b"}},
+		{[]string{"-s="}, []string{}},
+		{[]string{"-s=,"}, []string{"", ""}},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		s := f.StringSlice("s", []string{"default"}, "")
+		if err := f.Parse(tt.args); err != nil {
+			t.Errorf("Parse(%q): %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(*s, tt.want) {
+			t.Errorf("Parse(%q): s = %q, want %q", tt.args, *s, tt.want)
+		}
+
+		g := newTestSet()
+		gs := g.StringSlice("s", []string{"other"}, "")
+		roundTrip(t, f, g)
+		if !reflect.DeepEqual(*gs, *s) {
+			t.Errorf("round trip of %q gave %q", *s, *gs)
+		}
+	}
+}
+
+func TestStringMap(t *testing.T) {
+	tests := []struct {
+		args []string
+		want map[string]string
+	}{
+		{nil, map[string]string{"d": "1"}},
+		{[]string{"-m=a=1,b=2"}, map[string]string{"a": "1", "b": "2"}},
+		{[]string{"-m=a=1", "-m=b=2,a=3"}, map[string]string{"a": "3", "b": "2"}},
+		{[]string{`-m=k\=ey=v\,al=ue`}, map[string]string{"k=ey": "v,al=ue"}},
+		{[]string{"-m=a="}, map[string]string{"a": ""}},
+		{[]string{"-m="}, map[string]string{}},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		m := f.StringMap("m", map[string]string{"d": "1"}, "")
+		if err := f.Parse(tt.args); err != nil {
+			t.Errorf("Parse(%q): %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(*m, tt.want) {
+			t.Errorf("Parse(%q): m = %v, want %v", tt.args, *m, tt.want)
+		}
+
+		g := newTestSet()
+		gm := g.StringMap("m", map[string]string{"other": "x"}, "")
+		roundTrip(t, f, g)
+		if !reflect.DeepEqual(*gm, *m) {
+			t.Errorf("round trip of %v gave %v", *m, *gm)
+		}
+	}
+
+	f := newTestSet()
+	m := f.StringMap("m", nil, "")
+	if err := f.Set("m", "a=1,b"); err == nil {
+		t.Error("pair without '=' accepted")
+	}
+	if *m != nil {
+		t.Errorf("m = %v after a bad value, want nil", *m)
+	}
+}
+
+func TestTime(t *testing.T) {
+	def := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		layout string
+		in     string
+		want   time.Time
+		ok     bool
+	}{
+		{"", "2021-06-07T08:09:10Z", time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC), true},
+		{"", "2021-06-07", def, false},
+		{"2006-01-02", "2021-06-07", time.Date(2021, 6, 7, 0, 0, 0, 0, time.UTC), true},
+		{"2006-01-02", " 2021-06-07 ", time.Date(2021, 6, 7, 0, 0, 0, 0, time.UTC), true},
+		{"2006-01-02", "June 7", def, false},
+		{time.Kitchen, "3:04PM", time.Date(0, 1, 1, 15, 4, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		v := f.Time("t", def, tt.layout, "")
+		err := f.Set("t", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("layout %q: Set(t, %q) error = %v, want ok=%v", tt.layout, tt.in, err, tt.ok)
+		}
+		if !v.Equal(tt.want) {
+			t.Errorf("layout %q: Set(t, %q): t = %v, want %v", tt.layout, tt.in, *v, tt.want)
+		}
+
+		g := newTestSet()
+		gv := g.Time("t", time.Time{}, tt.layout, "")
+		roundTrip(t, f, g)
+		layout := tt.layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		if got, want := gv.Format(layout), v.Format(layout); got != want {
+			t.Errorf("layout %q: round trip of %s gave %s", tt.layout, want, got)
+		}
+	}
+
+	f := newTestSet()
+	v := f.Time("t", def, "", "")
+	before := time.Now()
+	if err := f.Set("t", "now"); err != nil {
+		t.Fatal(err)
+	}
+	if v.Before(before) || v.After(time.Now()) {
+		t.Errorf(`Set(t, "now") = %v, want about %v`, *v, before)
+	}
+}
+
+func TestEnum(t *testing.T) {
+	allowed := []string{"text", "json"}
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"json", "json", true},
+		{"text", "text", true},
+		{"JSON", "text", false},
+		{"", "text", false},
+		{"xml", "text", false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		v := f.Enum("format", allowed, "text", "log format")
+		err := f.Set("format", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(format, %q) error = %v, want ok=%v", tt.in, err, tt.ok)
+		}
+		if err != nil && !strings.Contains(err.Error(), "text, json") {
+			t.Errorf("Set(format, %q) error = %q, want the allowed values listed", tt.in, err)
+		}
+		if *v != tt.want {
+			t.Errorf("Set(format, %q): format = %q, want %q", tt.in, *v, tt.want)
+		}
+	}
+
+	f := newTestSet()
+	f.Enum("format", allowed, "text", "log format")
+	f.Enum("bare", allowed, "text", "")
+	if got, want := f.Lookup("format").Usage, "log format (one of text|json)"; got != want {
+		t.Errorf("usage = %q, want %q", got, want)
+	}
+	if got, want := f.Lookup("bare").Usage, "one of text|json"; got != want {
+		t.Errorf("usage = %q, want %q", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Enum with a default not in allowed did not panic")
+		}
+	}()
+	f.Enum("bad", allowed, "xml", "")
+}
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		abs  bool
+		in   string
+		want string
+		ok   bool
+	}{
+		{false, "https://example.com/api?q=1", "https://example.com/api?q=1", true},
+		{false, "/relative/path", "/relative/path", true},
+		{false, " https://example.com ", "https://example.com", true},
+		{false, "", "", true},
+		{false, "http://[::1", "http://old", false},
+		{true, "https://example.com/api", "https://example.com/api", true},
+		{true, "/relative/path", "http://old", false},
+		{true, "https:///nohost", "http://old", false},
+		{true, "", "", true},
+	}
+	for _, tt := range tests {
+		old, _ := url.Parse("http://old")
+		f := newTestSet()
+		var v **url.URL
+		if tt.abs {
+			v = f.AbsURL("u", old, "")
+		} else {
+			v = f.URL("u", old, "")
+		}
+		err := f.Set("u", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("abs=%v: Set(u, %q) error = %v, want ok=%v", tt.abs, tt.in, err, tt.ok)
+		}
+		got := ""
+		if *v != nil {
+			got = (*v).String()
+		}
+		if got != tt.want {
+			t.Errorf("abs=%v: Set(u, %q): u = %q, want %q", tt.abs, tt.in, got, tt.want)
+		}
+
+		g := newTestSet()
+		g.URL("u", nil, "")
+		roundTrip(t, f, g)
+		if s := g.Lookup("u").Value.String(); s != got {
+			t.Errorf("abs=%v: round trip of %q gave %q", tt.abs, got, s)
+		}
+	}
+}
+
+// point is a TextMarshaler and TextUnmarshaler written as "x,y".
+type point struct{ x, y int }
+
+func (p *point) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d,%d", &p.x, &p.y)
+	return err
+}
+
+func (p point) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", p.x, p.y)), nil
+}
+
+// upper is a TextUnmarshaler with no MarshalText.
+type upper string
+
+func (u *upper) UnmarshalText(b []byte) error {
+	*u = upper(strings.ToUpper(string(b)))
+	return nil
+}
+
+func TestTextVar(t *testing.T) {
+	tests := []struct {
+		in   string
+		want point
+		ok   bool
+	}{
+		{"3,4", point{3, 4}, true},
+		{"-1,0", point{-1, 0}, true},
+		{"3", point{3, 2}, false},
+		{"a,b", point{1, 2}, false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		p := point{1, 2}
+		f.TextVar(&p, "p", "a point")
+		if got := f.Lookup("p").DefValue; got != "1,2" {
+			t.Errorf("DefValue = %q, want %q", got, "1,2")
+		}
+		err := f.Set("p", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(p, %q) error = %v, want ok=%v", tt.in, err, tt.ok)
+		}
+		if tt.ok && p != tt.want {
+			t.Errorf("Set(p, %q): p = %v, want %v", tt.in, p, tt.want)
+		}
+		if got := f.Lookup("p").Value.(Getter).Get(); got != &p {
+			t.Errorf("Get() = %#v, want the pointer given to TextVar", got)
+		}
+
+		g := newTestSet()
+		var q point
+		g.TextVar(&q, "p", "a point")
+		roundTrip(t, f, g)
+		if q != p {
+			t.Errorf("round trip of %v gave %v", p, q)
+		}
+	}
+
+	f := newTestSet()
+	u := upper("x")
+	f.TextVar(&u, "u", "")
+	if err := f.Set("u", "shout"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Lookup("u").Value.String(); u != "SHOUT" || got != "SHOUT" {
+		t.Errorf("u = %q, String() = %q, want SHOUT", u, got)
+	}
+}
+
+func TestFunc(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+		ok   bool
+	}{
+		{nil, nil, true},
+		{[]string{"-add=a"}, []string{"a"}, true},
+		{[]string{"-add", "a", "-add=b", "--add", "c"}, []string{"a", "b", "c"}, true},
+		{[]string{"-add=a", "-add=bad", "-add=c"}, []string{"a"}, false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		var got []string
+		f.Func("add", "add an item", func(s string) error {
+			if s == "bad" {
+				return errors.New("bad item")
+			}
+			got = append(got, s)
+			return nil
+		})
+		err := f.Parse(tt.args)
+		if (err == nil) != tt.ok {
+			t.Errorf("Parse(%q) error = %v, want ok=%v", tt.args, err, tt.ok)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q): items = %q, want %q", tt.args, got, tt.want)
+		}
+		config := f.Lookup("add")
+		if s, v := config.Value.String(), config.Value.(Getter).Get(); s != "" || v != nil {
+			t.Errorf("String, Get = %q, %v, want \"\", nil", s, v)
+		}
+	}
+}
+
+func TestDurationSlice(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []time.Duration
+	}{
+		{nil, []time.Duration{time.Second}},
+		{[]string{"-backoff=1s,2s,5s"}, []time.Duration{time.Second, 2 * time.Second, 5 * time.Second}},
+		{[]string{"-backoff=100ms, 1m30s"}, []time.Duration{100 * time.Millisecond, 90 * time.Second}},
+		{[]string{"-backoff=1s", "-backoff=2s,3s"}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}},
+		{[]string{"-backoff="}, []time.Duration{}},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		d := f.DurationSlice("backoff", []time.Duration{time.Second}, "")
+		if err := f.Parse(tt.args); err != nil {
+			t.Errorf("Parse(%q): %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(*d, tt.want) {
+			t.Errorf("Parse(%q): backoff = %v, want %v", tt.args, *d, tt.want)
+		}
+	}
+
+	f := newTestSet()
+	d := f.DurationSlice("backoff", []time.Duration{time.Second}, "")
+	if err := f.Set("backoff", "1s,soon,3s"); err == nil || !strings.Contains(err.Error(), `"soon"`) {
+		t.Errorf("Set(backoff, 1s,soon,3s) = %v, want error naming \"soon\"", err)
+	}
+	if want := []time.Duration{time.Second}; !reflect.DeepEqual(*d, want) {
+		t.Errorf("backoff = %v after a bad value, want %v", *d, want)
+	}
+
+	f, g := newTestSet(), newTestSet()
+	f.DurationSlice("backoff", []time.Duration{1500 * time.Millisecond, time.Hour}, "")
+	gd := g.DurationSlice("backoff", []time.Duration{time.Minute}, "")
+	roundTrip(t, f, g)
+	if want := []time.Duration{1500 * time.Millisecond, time.Hour}; !reflect.DeepEqual(*gd, want) {
+		t.Errorf("round trip gave %v, want %v", *gd, want)
+	}
+	if got := f.Lookup("backoff").Value.String(); got != "1.5s,1h0m0s" {
+		t.Errorf("String() = %q, want %q", got, "1.5s,1h0m0s")
+	}
+}
+
+func TestRegexp(t *testing.T) {
+	tests := []struct {
+		in    string
+		want  string
+		match string
+		ok    bool
+	}{
+		{`^err(or)?:`, `^err(or)?:`, "error: x", true},
+		{`\d+\s*ms`, `\d+\s*ms`, "10 ms", true},
+		{"", "", "", true},
+		{`a(b`, "default", "", false},
+		{`[z-a]`, "default", "", false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		re := f.Regexp("filter", regexp.MustCompile("default"), "")
+		err := f.Set("filter", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(filter, %q) error = %v, want ok=%v", tt.in, err, tt.ok)
+		}
+		if err != nil && !strings.Contains(err.Error(), tt.in) {
+			t.Errorf("Set(filter, %q) error = %q, want the pattern named", tt.in, err)
+		}
+		got := f.Lookup("filter").Value.String()
+		if got != tt.want {
+			t.Errorf("Set(filter, %q): filter = %q, want %q", tt.in, got, tt.want)
+		}
+		if tt.match != "" && !(*re).MatchString(tt.match) {
+			t.Errorf("Set(filter, %q): does not match %q", tt.in, tt.match)
+		}
+
+		g := newTestSet()
+		g.Regexp("filter", nil, "")
+		roundTrip(t, f, g)
+		if s := g.Lookup("filter").Value.String(); s != got {
+			t.Errorf("round trip of %q gave %q", got, s)
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	levels := map[string]int{"debug": -4, "info": 0, "warn": 4, "warning": 4, "error": 8}
+	tests := []struct {
+		in   string
+		want int
+		name string
+		ok   bool
+	}{
+		{"debug", -4, "debug", true},
+		{"DEBUG", -4, "debug", true},
+		{"Warning", 4, "warn", true},
+		{"error", 8, "error", true},
+		{"6", 6, "6", true},
+		{"verbose", 0, "info", false},
+		{"", 0, "info", false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		l := f.Level("loglevel", levels, 0, "")
+		err := f.Set("loglevel", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(loglevel, %q) error = %v, want ok=%v", tt.in, err, tt.ok)
+		}
+		if err != nil && !strings.Contains(err.Error(), "debug, info, warn, warning, error") {
+			t.Errorf("Set(loglevel, %q) error = %q, want the level names listed", tt.in, err)
+		}
+		if *l != tt.want {
+			t.Errorf("Set(loglevel, %q): level = %d, want %d", tt.in, *l, tt.want)
+		}
+		if got := f.Lookup("loglevel").Value.String(); got != tt.name {
+			t.Errorf("Set(loglevel, %q): String() = %q, want %q", tt.in, got, tt.name)
+		}
+
+		g := newTestSet()
+		gl := g.Level("loglevel", levels, 8, "")
+		roundTrip(t, f, g)
+		if *gl != *l {
+			t.Errorf("round trip of %d gave %d", *l, *gl)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+	tests := []struct {
+		mustExist, dirOK bool
+		in               string
+		ok               bool
+	}{
+		{true, false, file, true},
+		{true, false, dir, false},
+		{true, false, missing, false},
+		{true, true, dir, true},
+		{true, true, file, true},
+		{false, false, missing, true},
+		{false, false, dir, false},
+		{false, true, dir, true},
+		{true, false, "", true},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		p := f.Path("path", "default", "", tt.mustExist, tt.dirOK)
+		err := f.Set("path", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("mustExist=%v dirOK=%v: Set(path, %q) error = %v, want ok=%v", tt.mustExist, tt.dirOK, tt.in, err, tt.ok)
+		}
+		want := "default"
+		if tt.ok {
+			want = tt.in
+		}
+		if *p != want || f.Lookup("path").Value.(Getter).Get() != want {
+			t.Errorf("mustExist=%v dirOK=%v: Set(path, %q): path = %q, want %q", tt.mustExist, tt.dirOK, tt.in, *p, want)
+		}
+	}
+}
+
+func TestEncodedBytes(t *testing.T) {
+	tests := []struct {
+		base64 bool
+		length int
+		in     string
+		want   []byte
+		ok     bool
+	}{
+		{false, 0, "deadBEEF", []byte{0xde, 0xad, 0xbe, 0xef}, true},
+		{false, 0, "", nil, true},
+		{false, 0, "abc", []byte{9}, false},
+		{false, 0, "zz", []byte{9}, false},
+		{false, 2, "0102", []byte{1, 2}, true},
+		{false, 2, "010203", []byte{9}, false},
+		{true, 0, "3q2+7w==", []byte{0xde, 0xad, 0xbe, 0xef}, true},
+		{true, 0, "3q2-7w==", []byte{9}, false},
+		{true, 0, "3q2+7w", []byte{9}, false},
+		{true, 4, "3q2+7w==", []byte{0xde, 0xad, 0xbe, 0xef}, true},
+		{true, 3, "3q2+7w==", []byte{9}, false},
+	}
+	for _, tt := range tests {
+		f, g := newTestSet(), newTestSet()
+		var p, q *[]byte
+		if tt.base64 {
+			p = f.BytesBase64("key", tt.length, []byte{9}, "")
+			q = g.BytesBase64("key", 0, []byte{7}, "")
+		} else {
+			p = f.BytesHex("key", tt.length, []byte{9}, "")
+			q = g.BytesHex("key", 0, []byte{7}, "")
+		}
+		err := f.Set("key", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("base64=%v length=%d: Set(key, %q) error = %v, want ok=%v", tt.base64, tt.length, tt.in, err, tt.ok)
+		}
+		if !reflect.DeepEqual(*p, tt.want) {
+			t.Errorf("base64=%v length=%d: Set(key, %q): key = %x, want %x", tt.base64, tt.length, tt.in, *p, tt.want)
+		}
+		roundTrip(t, f, g)
+		if !bytes.Equal(*q, *p) {
+			t.Errorf("base64=%v: round trip of %x gave %x", tt.base64, *p, *q)
+		}
+	}
+}
+
+func TestFuncNotSaved(t *testing.T) {
+	var calls []string
+	define := func() *ConfigSet {
+		f := newTestSet()
+		f.Int("n", 1, "")
+		f.Func("fn", "", func(s string) error {
+			calls = append(calls, s)
+			return nil
+		})
+		return f
+	}
+	saves := map[string]func(*ConfigSet, *bytes.Buffer) error{
+		"SaveTo":   func(f *ConfigSet, b *bytes.Buffer) error { return f.SaveTo(b) },
+		"SaveJSON": func(f *ConfigSet, b *bytes.Buffer) error { return f.SaveJSON(b) },
+		"SaveTOML": func(f *ConfigSet, b *bytes.Buffer) error { return f.SaveTOML(b) },
+	}
+	loads := map[string]func(*ConfigSet, *bytes.Buffer) error{
+		"SaveTo":   func(f *ConfigSet, b *bytes.Buffer) error { return f.LoadFrom(b) },
+		"SaveJSON": func(f *ConfigSet, b *bytes.Buffer) error { return f.LoadJSON(b) },
+		"SaveTOML": func(f *ConfigSet, b *bytes.Buffer) error { return f.LoadTOML(b) },
+	}
+	for name, save := range saves {
+		f := define()
+		if err := f.Set("fn", "x"); err != nil {
+			t.Fatal(err)
+		}
+		calls = nil
+		var buf bytes.Buffer
+		if err := save(f, &buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if strings.Contains(buf.String(), "fn") {
+			t.Errorf("%s wrote the Func config:\n%s", name, buf.String())
+		}
+		if err := loads[name](define(), &buf); err != nil {
+			t.Errorf("loading what %s wrote: %v", name, err)
+		}
+		if calls != nil {
+			t.Errorf("loading what %s wrote called the Func config with %q", name, calls)
+		}
+	}
+}
+
+func TestIntSlice(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []int
+	}{
+		{nil, []int{1}},
+		{[]string{"-n=80,443,8080"}, []int{80, 443, 8080}},
+		{[]string{"-n=0x10,0755,-3"}, []int{16, 493, -3}},
+		{[]string{"-n=80", "-n=443,8080"}, []int{80, 443, 8080}},
+		{[]string{"-n="}, []int{}},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		n := f.IntSlice("n", []int{1}, "")
+		if err := f.Parse(tt.args); err != nil {
+			t.Errorf("Parse(%q): %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(*n, tt.want) {
+			t.Errorf("Parse(%q): n = %v, want %v", tt.args, *n, tt.want)
+		}
+	}
+
+	f := newTestSet()
+	f.IntSlice("n", nil, "")
+	if err := f.Set("n", "1,x,3"); err == nil || !strings.Contains(err.Error(), `"x"`) {
+		t.Errorf("Set(n, 1,x,3) = %v, want error naming \"x\"", err)
+	}
+
+	f, g := newTestSet(), newTestSet()
+	f.IntSlice("n", []int{80, -1, 0x10}, "")
+	n := g.IntSlice("n", []int{9}, "")
+	roundTrip(t, f, g)
+	if want := []int{80, -1, 16}; !reflect.DeepEqual(*n, want) {
+		t.Errorf("round trip gave %v, want %v", *n, want)
+	}
+}
+
+func TestIP(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"10.0.0.1", "10.0.0.1", true},
+		{" 192.168.1.1 ", "192.168.1.1", true},
+		{"2001:db8::1", "2001:db8::1", true},
+		{"2001:0db8:0000::0001", "2001:db8::1", true},
+		{"", "", true},
+		{"10.0.0.256", "", false},
+		{"garbage", "", false},
+		{"10.0.0.0/8", "", false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.IP("ip", nil, "")
+		err := f.Set("ip", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(ip, %q) error = %v, want ok=%v", tt.in, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if got := f.Lookup("ip").Value.String(); got != tt.want {
+			t.Errorf("Set(ip, %q): String() = %q, want %q", tt.in, got, tt.want)
+		}
+		g := newTestSet()
+		ip := g.IP("ip", net.IPv4(1, 2, 3, 4), "")
+		roundTrip(t, f, g)
+		if got := newIPValue(*ip, new(net.IP)).String(); got != tt.want {
+			t.Errorf("round trip of %q gave %q", tt.in, got)
+		}
+	}
+}
+
+func TestIPNet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8", true},
+		{"10.1.2.3/8", "10.0.0.0/8", true},
+		{"2001:db8::/32", "2001:db8::/32", true},
+		{"2001:db8::1/64", "2001:db8::/64", true},
+		{"", "", true},
+		{"10.0.0.0", "", false},
+		{"10.0.0.0/33", "", false},
+		{"garbage/8", "", false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		f.IPNet("net", net.IPNet{}, "")
+		err := f.Set("net", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(net, %q) error = %v, want ok=%v", tt.in, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if got := f.Lookup("net").Value.String(); got != tt.want {
+			t.Errorf("Set(net, %q): String() = %q, want %q", tt.in, got, tt.want)
+		}
+		g := newTestSet()
+		g.IPNet("net", net.IPNet{}, "")
+		roundTrip(t, f, g)
+		if got := g.Lookup("net").Value.String(); got != tt.want {
+			t.Errorf("round trip of %q gave %q", tt.in, got)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"1024", 1024, true},
+		{"1B", 1, true},
+		{"1KB", 1000, true},
+		{"1KiB", 1024, true},
+		{"1kib", 1024, true},
+		{"4MB", 4e6, true},
+		{"4 MiB", 4 << 20, true},
+		{"2GiB", 2 << 30, true},
+		{"1.5GiB", 3 << 29, true},
+		{"1.5KB", 1500, true},
+		{"7EiB", 7 << 60, true},
+		{"8EiB", 0, false},
+		{"-1", 0, false},
+		{"-1KB", 0, false},
+		{"", 0, false},
+		{"KB", 0, false},
+		{"1XB", 0, false},
+		{"1.2.3MB", 0, false},
+	}
+	for _, tt := range tests {
+		var v int64
+		err := newBytesValue(0, &v).Set(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(%q) error = %v, want ok=%v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && v != tt.want {
+			t.Errorf("Set(%q) = %d, want %d", tt.in, v, tt.want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{999, "999"},
+		{1000, "1KB"},
+		{1024, "1KiB"},
+		{1500, "1500"},
+		{4e6, "4MB"},
+		{32 << 20, "32MiB"},
+		{2 << 30, "2GiB"},
+		{1e9, "1GB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.in); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+		var v int64
+		if err := newBytesValue(0, &v).Set(tt.want); err != nil || v != tt.in {
+			t.Errorf("Set(%q) = %d, %v; want %d", tt.want, v, err, tt.in)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, 0},
+		{[]string{"-v"}, 1},
+		{[]string{"-v", "-v", "-v"}, 3},
+		{[]string{"-v=3"}, 3},
+		{[]string{"-v=3", "-v"}, 4},
+		{[]string{"-v", "-v=false"}, 0},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		v := f.Count("v", "")
+		if err := f.Parse(tt.args); err != nil {
+			t.Errorf("Parse(%q): %v", tt.args, err)
+			continue
+		}
+		if *v != tt.want {
+			t.Errorf("Parse(%q): v = %d, want %d", tt.args, *v, tt.want)
+		}
+		config := f.Lookup("v")
+		if got := config.Value.(Getter).Get(); got != tt.want {
+			t.Errorf("Parse(%q): Get() = %v, want %d", tt.args, got, tt.want)
+		}
+		if got, want := config.Value.String(), strconv.Itoa(tt.want); got != want {
+			t.Errorf("Parse(%q): String() = %q, want %q", tt.args, got, want)
+		}
+	}
+}
+
+func TestFloat32(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float32
+		ok   bool
+	}{
+		{"1.5", 1.5, true},
+		{"-0.25", -0.25, true},
+		{"3.4e38", 3.4e38, true},
+		{"1e-50", 0, true},
+		{"1e40", 2, false},
+		{"-1e40", 2, false},
+		{"x", 2, false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		v := f.Float32("v", 2, "")
+		err := f.Set("v", tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(v, %q) error = %v, want ok=%v", tt.in, err, tt.ok)
+		}
+		if *v != tt.want {
+			t.Errorf("Set(v, %q): v = %v, want %v", tt.in, *v, tt.want)
+		}
+	}
+	if got := newFloat32Value(0.1, new(float32)).String(); got != "0.1" {
+		t.Errorf("String() = %q, want 0.1", got)
+	}
+}
+
+func TestInt32Uint32(t *testing.T) {
+	tests := []struct {
+		in  string
+		i32 int32
+		u32 uint32
+		iok bool
+		uok bool
+	}{
+		{"42", 42, 42, true, true},
+		{"0x7fffffff", 1<<31 - 1, 1<<31 - 1, true, true},
+		{"-2147483648", -1 << 31, 7, true, false},
+		{"3000000000", 7, 3000000000, false, true},
+		{"4294967295", 7, 1<<32 - 1, false, true},
+		{"4294967296", 7, 7, false, false},
+		{"-1", -1, 7, true, false},
+		{"x", 7, 7, false, false},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		i := f.Int32("i", 7, "")
+		u := f.Uint32("u", 7, "")
+		if err := f.Set("i", tt.in); (err == nil) != tt.iok || *i != tt.i32 {
+			t.Errorf("Set(i, %q): i = %d, error = %v; want %d, ok=%v", tt.in, *i, err, tt.i32, tt.iok)
+		}
+		if err := f.Set("u", tt.in); (err == nil) != tt.uok || *u != tt.u32 {
+			t.Errorf("Set(u, %q): u = %d, error = %v; want %d, ok=%v", tt.in, *u, err, tt.u32, tt.uok)
+		}
+	}
+}
+
+func TestSetKeepsValueOnError(t *testing.T) {
+	// One more than the largest int and uint on this platform.
+	intOverflow := "9223372036854775808"
+	uintOverflow := "18446744073709551616"
+	if strconv.IntSize == 32 {
+		intOverflow, uintOverflow = "2147483648", "4294967296"
+	}
+	tests := []struct {
+		define func(f *ConfigSet)
+		bad    []string
+	}{
+		{func(f *ConfigSet) { f.Int("v", 7, "") }, []string{intOverflow, "-" + uintOverflow, "x"}},
+		{func(f *ConfigSet) { f.Uint("v", 7, "") }, []string{uintOverflow, "-1", "x"}},
+		{func(f *ConfigSet) { f.Int64("v", 7, "") }, []string{"9223372036854775808", "x"}},
+		{func(f *ConfigSet) { f.Uint64("v", 7, "") }, []string{"18446744073709551616", "-1"}},
+		{func(f *ConfigSet) { f.Float64("v", 7, "") }, []string{"1e400", "x"}},
+		{func(f *ConfigSet) { f.Duration("v", 7, "") }, []string{"7", "x"}},
+		{func(f *ConfigSet) { f.Bool("v", true, "") }, []string{"x"}},
+	}
+	for _, tt := range tests {
+		f := newTestSet()
+		tt.define(f)
+		want := f.Lookup("v").Value.String()
+		for _, s := range tt.bad {
+			if err := f.Set("v", s); err == nil {
+				t.Errorf("%T: Set(v, %q) succeeded", f.Lookup("v").Value, s)
+			}
+			if got := f.Lookup("v").Value.String(); got != want {
+				t.Errorf("%T: Set(v, %q) changed value to %s", f.Lookup("v").Value, s, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package goflagconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// WatchInterval is how often Watch checks the config file for changes.
+var WatchInterval = time.Second
+
+// OnReload registers fn to be called after each successful reload by Watch.
+// Functions are called in the order they were registered.
+func (f *ConfigSet) OnReload(fn func()) {
+	f.checkNotSection("OnReload")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reloadFuncs = append(f.reloadFuncs, fn)
+}
+
+// OnReload registers fn to be called after each successful reload of the
+// command-line config set by Watch.
+func OnReload(fn func()) {
+	Configuration.OnReload(fn)
+}
+
+// Watch checks the file configured in NewConfigSet every WatchInterval and
+// reloads it as Load does whenever its modification time or size changes.
+// Reloading sets only the configs that appear in the file; the others keep
+// their values. Errors from reloading are sent on the returned channel,
+// whatever the error handling policy, so a bad line neither exits nor
+// panics; the caller must keep receiving from the channel, which is closed
+// once ctx is done.
+// Watch returns an error if the file cannot be examined to begin with.
+func (f *ConfigSet) Watch(ctx context.Context) (<-chan error, error) {
+	if f.owner != nil {
+		return nil, f.errSection()
+	}
+	if f.filename == "" {
+		return nil, errors.New("no file to watch")
+	}
+	fi, err := os.Stat(f.filename)
+	if err != nil {
+		return nil, err
+	}
+	errc := make(chan error)
+	go func() {
+		defer close(errc)
+		ticker := time.NewTicker(WatchInterval)
+		defer ticker.Stop()
+		last := fi
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			fi, err := os.Stat(f.filename)
+			if err == nil && fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size() {
+				continue
+			}
+			if err == nil {
+				last = fi
+				err = f.reload()
+			}
+			if err == nil {
+				f.mu.Lock()
+				funcs := f.reloadFuncs
+				f.unlock()
+				for _, fn := range funcs {
+					fn()
+				}
+				continue
+			}
+			select {
+			case errc <- err:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return errc, nil
+}
+
+// reload reads the file configured in NewConfigSet as Load does, but
+// returns every error rather than handling it according to the error
+// handling policy, as Watch runs it in a goroutine of its own.
+func (f *ConfigSet) reload() error {
+	f.logf("reloading config from %s", f.filename)
+	in, err := os.Open(f.filename)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	defer in.Close()
+	return f.load(in, f.filename, nil, true)
+}
+
+// Watch reloads the command-line config set whenever its file changes.
+func Watch(ctx context.Context) (<-chan error, error) {
+	return Configuration.Watch(ctx)
+}
@@ -0,0 +1,145 @@
+package goflagconfig
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	defer func(d time.Duration) { WatchInterval = d }(WatchInterval)
+	WatchInterval = 5 * time.Millisecond
+
+	name := filepath.Join(t.TempDir(), "test.conf")
+	if err := os.WriteFile(name, []byte("a=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := newTestSet()
+	f.filename = name
+	a := f.Int("a", 0, "")
+	b := f.Int("b", 0, "")
+	if err := f.Load(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := make(chan bool, 10)
+	f.OnReload(func() { reloaded <- true })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc, err := f.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*b = 5
+
+	tests := []struct {
+		content string
+		a       int
+		ok      bool
+	}{
+		{"a=22\n", 22, true},
+		{"a=bad\n", 22, false},
+		{"a=4444\n", 4444, true},
+	}
+	for _, tt := range tests {
+		// Replace the file in one step, so that Watch cannot see it half
+		// written.
+		tmp := name + ".tmp"
+		if err := os.WriteFile(tmp, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-reloaded:
+			if !tt.ok {
+				t.Errorf("reload of %q succeeded", tt.content)
+			}
+		case err := <-errc:
+			if tt.ok {
+				t.Errorf("reload of %q: %v", tt.content, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no reload after writing %q", tt.content)
+		}
+		if *a != tt.a {
+			t.Errorf("after writing %q: a = %d, want %d", tt.content, *a, tt.a)
+		}
+		if *b != 5 {
+			t.Errorf("after writing %q: b = %d, want 5", tt.content, *b)
+		}
+	}
+
+	cancel()
+	for range errc {
+	}
+
+	if _, err := newTestSet().Watch(context.Background()); err == nil {
+		t.Error("Watch with no file succeeded")
+	}
+	g := newTestSet()
+	g.filename = filepath.Join(t.TempDir(), "missing.conf")
+	if _, err := g.Watch(context.Background()); err == nil {
+		t.Error("Watch of a missing file succeeded")
+	}
+}
+
+func TestWatchErrorHandling(t *testing.T) {
+	defer func(d time.Duration) { WatchInterval = d }(WatchInterval)
+	WatchInterval = 5 * time.Millisecond
+
+	name := filepath.Join(t.TempDir(), "test.conf")
+	if err := os.WriteFile(name, []byte("a=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A bad line must not panic in Watch's goroutine.
+	f := NewConfigSet(name, PanicOnError)
+	f.SetOutput(io.Discard)
+	a := f.Int("a", 0, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errc, err := f.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string) {
+		t.Helper()
+		tmp := name + ".tmp"
+		if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	receive := func() error {
+		t.Helper()
+		select {
+		case err := <-errc:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("no error from reload")
+			return nil
+		}
+	}
+
+	write("a=bad\nb=1\na=2\n")
+	err = receive()
+	if err == nil || !strings.Contains(err.Error(), `invalid value "bad"`) || !strings.Contains(err.Error(), "no such config b") {
+		t.Errorf("reload error = %v, want both bad lines", err)
+	}
+	if *a != 2 {
+		t.Errorf("a = %d, want 2", *a)
+	}
+
+	f.Freeze()
+	write("a=33\n")
+	if err := receive(); !errors.Is(err, ErrFrozen) {
+		t.Errorf("reload of a frozen set = %v, want ErrFrozen", err)
+	}
+}